@@ -0,0 +1,119 @@
+package simplehttp
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthScope limits which requests receive the client's credentials.
+type AuthScope int
+
+const (
+	// AuthBaseURLHost sends credentials only to the host in BaseURL. It's the
+	// default; with no BaseURL host, credentials go to every request.
+	AuthBaseURLHost AuthScope = iota
+	// AuthAnyHost sends credentials with every request.
+	AuthAnyHost
+)
+
+// credentials are either a username and password for Basic auth or a bearer
+// token; the zero value sends nothing.
+type credentials struct {
+	username string
+	password string
+	basic    bool
+	token    string
+}
+
+func (c credentials) apply(req *http.Request) {
+	switch {
+	case c.basic:
+		req.SetBasicAuth(c.username, c.password)
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+func (c credentials) set() bool {
+	return c.basic || c.token != ""
+}
+
+// SetBasicAuth sends HTTP Basic credentials with the client's requests,
+// replacing any bearer token.
+func (client *HTTPClient) SetBasicAuth(username, password string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.auth = credentials{username: username, password: password, basic: true}
+}
+
+// SetBearerToken sends an "Authorization: Bearer" header with the client's
+// requests, replacing any Basic credentials.
+func (client *HTTPClient) SetBearerToken(token string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.auth = credentials{token: token}
+}
+
+func (client *HTTPClient) ClearAuth() {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.auth = credentials{}
+}
+
+func (client *HTTPClient) SetAuthScope(scope AuthScope) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.authScope = scope
+}
+
+// BasicAuth sends HTTP Basic credentials with this request only, in place of
+// the client's credentials and regardless of the auth scope.
+func (r *Request) BasicAuth(username, password string) *Request {
+	r.auth = credentials{username: username, password: password, basic: true}
+	return r
+}
+
+// BearerToken sends a bearer token with this request only, in place of the
+// client's credentials and regardless of the auth scope.
+func (r *Request) BearerToken(token string) *Request {
+	r.auth = credentials{token: token}
+	return r
+}
+
+// authHost returns the host the client's credentials are scoped to: the
+// BaseURL host, or failing that the host of the call's first request.
+func (client snapshot) authHost(first *url.URL) string {
+	if base, err := url.Parse(client.baseURL); err == nil && base.Host != "" {
+		return base.Host
+	}
+	return first.Host
+}
+
+// applyAuth adds the request's own credentials, or else the client's when
+// req is in scope.
+func applyAuth(req *http.Request, r *Request, client snapshot) {
+	if r.auth.set() {
+		r.auth.apply(req)
+		return
+	}
+	if !client.auth.set() {
+		return
+	}
+	if client.authScope == AuthBaseURLHost && !strings.EqualFold(client.authHost(req.URL), req.URL.Host) {
+		return
+	}
+	client.auth.apply(req)
+}
+
+// scopeAuthOnRedirect drops the client's credentials from a redirect that
+// leaves the scoped host. net/http already strips Authorization when leaving
+// the original domain, but keeps it for subdomains and other ports.
+func scopeAuthOnRedirect(req *http.Request, via []*http.Request, r *Request, client snapshot) {
+	if r.auth.set() || !client.auth.set() || client.authScope != AuthBaseURLHost {
+		return
+	}
+	if !strings.EqualFold(client.authHost(via[0].URL), req.URL.Host) {
+		req.Header.Del("Authorization")
+	}
+}
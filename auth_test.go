@@ -0,0 +1,140 @@
+package simplehttp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// authorizationSeen decodes the /header echo and returns its Authorization.
+func authorizationSeen(t *testing.T, response HTTPResponse) string {
+	t.Helper()
+	var got http.Header
+	if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return got.Get("Authorization")
+}
+
+func TestAuth(t *testing.T) { //nolint:funlen // subtests for each auth mode
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	redirector := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	t.Run("Bearer", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("goodtoken")
+
+		response, err := c.Get("/protected")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "good" {
+			t.Errorf("expected body %q, got %q", "good", response.Body)
+		}
+	})
+
+	t.Run("Basic", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBasicAuth("user", "p@ss")
+
+		response, err := c.Get("/header")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:p@ss"))
+		if got := authorizationSeen(t, response); got != want {
+			t.Errorf("expected Authorization %q, got %q", want, got)
+		}
+	})
+
+	t.Run("PerRequest", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("badtoken")
+
+		response, err := c.R().BearerToken("goodtoken").Get("/protected")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "good" {
+			t.Errorf("expected body %q, got %q", "good", response.Body)
+		}
+
+		response, err = c.R().BasicAuth("a", "b").Get("/header")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := authorizationSeen(t, response); got != "Basic YTpi" {
+			t.Errorf("expected Authorization %q, got %q", "Basic YTpi", got)
+		}
+	})
+
+	t.Run("Cleared", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("goodtoken")
+		c.ClearAuth()
+
+		response, err := c.Get("/protected")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+		}
+	})
+
+	t.Run("ScopedOnRedirect", func(t *testing.T) {
+		c := New(redirector.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("secret")
+
+		response, err := c.R().Query("to", ts.URL+"/header").Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := authorizationSeen(t, response); got != "" {
+			t.Errorf("expected credentials to stay on the BaseURL host, got %q", got)
+		}
+	})
+
+	t.Run("AnyHost", func(t *testing.T) {
+		c := New(redirector.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("secret")
+		c.SetAuthScope(AuthAnyHost)
+
+		response, err := c.R().Query("to", ts.URL+"/header").Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// net/http itself keeps Authorization across ports of the same host
+		if got := authorizationSeen(t, response); got != "Bearer secret" {
+			t.Errorf("expected Authorization %q, got %q", "Bearer secret", got)
+		}
+	})
+
+	t.Run("NoBaseURL", func(t *testing.T) {
+		c := New(redirector.URL)
+		c.Client = ts.Client()
+		c.SetBearerToken("secret")
+		c.BaseURL = ""
+
+		response, err := c.Get(ts.URL + "/header")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := authorizationSeen(t, response); got != "Bearer secret" {
+			t.Errorf("expected credentials without a BaseURL to follow the request host, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package simplehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+)
+
+// BodyEncoding selects how Data fields are encoded into the request body.
+type BodyEncoding int
+
+const (
+	EncodeJSON BodyEncoding = iota
+	EncodeForm
+	EncodeMultipart
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeForm = "application/x-www-form-urlencoded"
+)
+
+// SetBodyEncoding changes how the client encodes Data fields by default.
+func (client *HTTPClient) SetBodyEncoding(encoding BodyEncoding) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.encoding = encoding
+}
+
+// requestBody is an encoded request body. open returns a fresh reader over
+// it and is nil when there is nothing to send; length is -1 when unknown.
+// Only replayable bodies may be opened more than once.
+type requestBody struct {
+	open        func() (io.ReadCloser, error)
+	length      int64
+	contentType string
+	replayable  bool
+}
+
+func bytesBody(b []byte, contentType string) requestBody {
+	return requestBody{
+		open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		},
+		length:      int64(len(b)),
+		contentType: contentType,
+		replayable:  true,
+	}
+}
+
+// readerBody streams rd. Seekable readers are rewound to their starting
+// offset on every open so the body can be replayed.
+func readerBody(rd io.Reader, size int64) (requestBody, error) {
+	if rd == nil {
+		return requestBody{}, nil
+	}
+	body := requestBody{length: size}
+	if size < 0 {
+		body.length = -1
+	}
+
+	seeker, ok := rd.(io.Seeker)
+	if !ok {
+		body.open = func() (io.ReadCloser, error) {
+			return io.NopCloser(rd), nil
+		}
+		return body, nil
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return requestBody{}, err
+	}
+	body.open = func() (io.ReadCloser, error) {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(rd), nil
+	}
+	body.replayable = true
+	return body, nil
+}
+
+// encodeBody builds the request body. A body set with Request.BodyReader or
+// Request.JSON replaces the Data fields; otherwise they are encoded per the
+// request or client BodyEncoding.
+func encodeBody(r *Request, client snapshot) (requestBody, error) {
+	if r.hasReader {
+		return readerBody(r.reader, r.readerSize)
+	}
+	if r.hasJSON {
+		b, err := json.Marshal(r.json)
+		return bytesBody(b, contentTypeJSON), err
+	}
+
+	data := merge(client.data, r.data)
+	encoding := client.encoding
+	if r.hasEncoding {
+		encoding = r.encoding
+	}
+
+	switch encoding {
+	case EncodeForm, EncodeMultipart:
+		values := make(url.Values, len(data)+len(r.form))
+		for k, v := range data {
+			values.Set(k, v)
+		}
+		for k, v := range r.form {
+			values[k] = v
+		}
+		if len(values) == 0 && len(r.files) == 0 {
+			return requestBody{}, nil
+		}
+		if encoding == EncodeMultipart {
+			return multipartBody(values, r.files), nil
+		}
+		return bytesBody([]byte(values.Encode()), contentTypeForm), nil
+	case EncodeJSON:
+	}
+
+	if len(data) == 0 {
+		return requestBody{}, nil
+	}
+	b, err := json.Marshal(data)
+	return bytesBody(b, contentTypeJSON), err
+}
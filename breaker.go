@@ -0,0 +1,254 @@
+package simplehttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBreakerMinRequests = 10
+	defaultBreakerInterval    = time.Minute
+	defaultBreakerCoolDown    = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned, wrapped, for requests refused because the
+// circuit breaker for their host is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerSettings configures a circuit breaker kept separately for each host.
+// A closed breaker counts outcomes over a rolling Interval and opens once at
+// least MinRequests calls have been seen and FailureRatio of them failed. An
+// open breaker refuses requests for CoolDown, then turns half-open and lets
+// HalfOpenRequests trial requests through: if they all succeed it closes, and
+// any failure opens it again. The zero value disables the breaker.
+type BreakerSettings struct {
+	FailureRatio     float64
+	MinRequests      int           // defaults to 10
+	Interval         time.Duration // defaults to 1m
+	CoolDown         time.Duration // defaults to 30s
+	HalfOpenRequests int           // defaults to 1
+	// IsFailure classifies the outcome of an attempt; by default transport
+	// errors and 5xx responses are failures. Attempts canceled by the caller
+	// aren't counted either way.
+	IsFailure func(response *http.Response, err error) bool
+	// OnStateChange is called, outside any lock, whenever a host's breaker
+	// changes state.
+	OnStateChange func(host string, from, to BreakerState)
+}
+
+// SetCircuitBreaker installs fresh per-host circuit breakers; a zero
+// FailureRatio removes them.
+func (client *HTTPClient) SetCircuitBreaker(settings BreakerSettings) {
+	var b *breakers
+	if settings.FailureRatio > 0 {
+		if settings.MinRequests <= 0 {
+			settings.MinRequests = defaultBreakerMinRequests
+		}
+		if settings.Interval <= 0 {
+			settings.Interval = defaultBreakerInterval
+		}
+		if settings.CoolDown <= 0 {
+			settings.CoolDown = defaultBreakerCoolDown
+		}
+		if settings.HalfOpenRequests <= 0 {
+			settings.HalfOpenRequests = 1
+		}
+		if settings.IsFailure == nil {
+			settings.IsFailure = defaultIsFailure
+		}
+		b = &breakers{settings: settings, hosts: make(map[string]*breaker)}
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.breakers = b
+}
+
+// BreakerState reports the state of the circuit breaker for host, which is
+// closed if breakers are disabled or the host hasn't been seen.
+func (client *HTTPClient) BreakerState(host string) BreakerState {
+	client.mu.RLock()
+	b := client.breakers
+	client.mu.RUnlock()
+	if b == nil {
+		return BreakerClosed
+	}
+	state, _ := b.host(host).current(time.Now())
+	return state
+}
+
+func defaultIsFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response.StatusCode >= http.StatusInternalServerError
+}
+
+type breakers struct {
+	settings BreakerSettings
+
+	mu    sync.Mutex
+	hosts map[string]*breaker
+}
+
+func (b *breakers) host(host string) *breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	br, ok := b.hosts[host]
+	if !ok {
+		br = &breaker{settings: &b.settings, host: host, expiry: time.Now().Add(b.settings.Interval)}
+		b.hosts[host] = br
+	}
+	return br
+}
+
+// breaker is the state machine for one host. generation increases on every
+// state change and window reset, so late results from an earlier generation
+// are ignored.
+type breaker struct {
+	settings *BreakerSettings
+	host     string
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	expiry     time.Time
+	requests   int
+	failures   int
+	successes  int
+}
+
+// current advances time-based transitions and returns the resulting state,
+// along with the change made, if any, to report once the lock is released.
+func (br *breaker) current(now time.Time) (BreakerState, func()) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.advance(now)
+}
+
+func (br *breaker) advance(now time.Time) (BreakerState, func()) {
+	switch br.state {
+	case BreakerClosed:
+		if now.After(br.expiry) {
+			br.reset(now.Add(br.settings.Interval))
+		}
+	case BreakerOpen:
+		if now.After(br.expiry) {
+			return br.state, br.transition(BreakerHalfOpen, now)
+		}
+	case BreakerHalfOpen:
+	}
+	return br.state, nil
+}
+
+func (br *breaker) reset(expiry time.Time) {
+	br.generation++
+	br.requests, br.failures, br.successes = 0, 0, 0
+	br.expiry = expiry
+}
+
+func (br *breaker) transition(to BreakerState, now time.Time) func() {
+	from := br.state
+	br.state = to
+	switch to {
+	case BreakerClosed:
+		br.reset(now.Add(br.settings.Interval))
+	case BreakerOpen:
+		br.reset(now.Add(br.settings.CoolDown))
+	case BreakerHalfOpen:
+		br.reset(time.Time{})
+	}
+
+	if br.settings.OnStateChange == nil {
+		return nil
+	}
+	cb, host := br.settings.OnStateChange, br.host
+	return func() { cb(host, from, to) }
+}
+
+// allow admits a request, returning the generation its result belongs to.
+func (br *breaker) allow(now time.Time) (uint64, error) {
+	br.mu.Lock()
+	state, notify := br.advance(now)
+	generation := br.generation
+	var err error
+	switch {
+	case state == BreakerOpen:
+		err = fmt.Errorf("%w for %s", ErrCircuitOpen, br.host)
+	case state == BreakerHalfOpen && br.requests >= br.settings.HalfOpenRequests:
+		err = fmt.Errorf("%w for %s: trial requests in flight", ErrCircuitOpen, br.host)
+	default:
+		br.requests++
+	}
+	br.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+	return generation, err
+}
+
+// cancel gives back the slot taken by allow for a request that was never sent.
+func (br *breaker) cancel(generation uint64) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	if generation == br.generation && br.requests > 0 {
+		br.requests--
+	}
+}
+
+// record counts the outcome of a request admitted in generation.
+func (br *breaker) record(generation uint64, failed bool, now time.Time) {
+	br.mu.Lock()
+	state, notify := br.advance(now)
+	if generation == br.generation {
+		var change func()
+		switch state {
+		case BreakerClosed:
+			if failed {
+				br.failures++
+			}
+			if br.requests >= br.settings.MinRequests &&
+				float64(br.failures)/float64(br.requests) >= br.settings.FailureRatio {
+				change = br.transition(BreakerOpen, now)
+			}
+		case BreakerHalfOpen:
+			if failed {
+				change = br.transition(BreakerOpen, now)
+			} else if br.successes++; br.successes >= br.settings.HalfOpenRequests {
+				change = br.transition(BreakerClosed, now)
+			}
+		case BreakerOpen:
+		}
+		if change != nil {
+			notify = change
+		}
+	}
+	br.mu.Unlock()
+
+	if notify != nil {
+		notify()
+	}
+}
@@ -0,0 +1,183 @@
+package simplehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// toggleServer answers 500 while failing is set and 200 otherwise, counting
+// the requests that reach it.
+func toggleServer(t *testing.T) (ts *httptest.Server, failing *int32, calls *int32) {
+	t.Helper()
+	failing, calls = new(int32), new(int32)
+	ts = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if atomic.LoadInt32(failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, failing, calls
+}
+
+func TestCircuitBreaker(t *testing.T) { //nolint:funlen // walks the breaker through each state
+	t.Parallel()
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		ts, failing, calls := toggleServer(t)
+		u, _ := url.Parse(ts.URL)
+
+		var mu sync.Mutex
+		var changes []string
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetCircuitBreaker(BreakerSettings{
+			FailureRatio: 0.5,
+			MinRequests:  4,
+			CoolDown:     100 * time.Millisecond,
+			OnStateChange: func(host string, from, to BreakerState) {
+				mu.Lock()
+				defer mu.Unlock()
+				changes = append(changes, fmt.Sprintf("%s:%s->%s", host, from, to))
+			},
+		})
+
+		atomic.StoreInt32(failing, 1)
+		for i := 0; i < 4; i++ {
+			if _, err := c.Get("/"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if got := c.BreakerState(u.Host); got != BreakerOpen {
+			t.Fatalf("expected breaker to be open, got %s", got)
+		}
+
+		_, err := c.Get("/")
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected ErrCircuitOpen, got: %v", err)
+		}
+		if got := atomic.LoadInt32(calls); got != 4 {
+			t.Errorf("expected the open breaker to stop requests reaching the server, got %d calls", got)
+		}
+
+		atomic.StoreInt32(failing, 0)
+		time.Sleep(150 * time.Millisecond)
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+
+		want := []string{
+			u.Host + ":closed->open",
+			u.Host + ":open->half-open",
+			u.Host + ":half-open->closed",
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !cmp.Equal(want, changes) {
+			t.Error(cmp.Diff(want, changes))
+		}
+	})
+
+	t.Run("HalfOpenFailure", func(t *testing.T) {
+		ts, failing, _ := toggleServer(t)
+		u, _ := url.Parse(ts.URL)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetCircuitBreaker(BreakerSettings{FailureRatio: 1, MinRequests: 1, CoolDown: 50 * time.Millisecond})
+
+		atomic.StoreInt32(failing, 1)
+		if _, err := c.Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(80 * time.Millisecond)
+		if got := c.BreakerState(u.Host); got != BreakerHalfOpen {
+			t.Fatalf("expected breaker to be half-open, got %s", got)
+		}
+		if _, err := c.Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := c.BreakerState(u.Host); got != BreakerOpen {
+			t.Errorf("expected a failed trial to reopen the breaker, got %s", got)
+		}
+	})
+
+	t.Run("HalfOpenCanceled", func(t *testing.T) {
+		ts, failing, _ := toggleServer(t)
+		u, _ := url.Parse(ts.URL)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetCircuitBreaker(BreakerSettings{FailureRatio: 1, MinRequests: 1, CoolDown: 50 * time.Millisecond})
+
+		atomic.StoreInt32(failing, 1)
+		if _, err := c.Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(80 * time.Millisecond)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := c.GetContext(ctx, "/"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+		if got := c.BreakerState(u.Host); got != BreakerHalfOpen {
+			t.Errorf("expected a canceled trial to leave the breaker half-open, got %s", got)
+		}
+		if _, err := c.Get("/"); err != nil {
+			t.Fatalf("expected another trial to be allowed, got: %v", err)
+		}
+		if got := c.BreakerState(u.Host); got != BreakerOpen {
+			t.Errorf("expected the failed trial to reopen the breaker, got %s", got)
+		}
+	})
+
+	t.Run("PerHost", func(t *testing.T) {
+		bad, failing, _ := toggleServer(t)
+		good, _, _ := toggleServer(t)
+		atomic.StoreInt32(failing, 1)
+
+		c := New("")
+		c.Client = bad.Client()
+		c.SetCircuitBreaker(BreakerSettings{FailureRatio: 1, MinRequests: 1, CoolDown: time.Minute})
+
+		if _, err := c.Get(bad.URL + "/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.Get(bad.URL + "/"); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("expected ErrCircuitOpen, got: %v", err)
+		}
+		if _, err := c.Get(good.URL + "/"); err != nil {
+			t.Errorf("expected other hosts to be unaffected, got: %v", err)
+		}
+	})
+
+	t.Run("StopsRetries", func(t *testing.T) {
+		ts, failing, calls := toggleServer(t)
+		atomic.StoreInt32(failing, 1)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, MinBackoff: time.Millisecond, RetryableStatus: []int{500}})
+		c.SetCircuitBreaker(BreakerSettings{FailureRatio: 1, MinRequests: 2, CoolDown: time.Minute})
+
+		_, err := c.Get("/")
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("expected ErrCircuitOpen once the breaker trips mid-retry, got: %v", err)
+		}
+		if got := atomic.LoadInt32(calls); got != 2 {
+			t.Errorf("expected 2 calls before the breaker opened, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,370 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errNoCookieJar = errors.New("cookie jar not enabled; pass WithCookieJar to New")
+
+// WithCookieJar gives the client a cookie jar, so cookies set by responses
+// are sent with later requests. The jar can be saved to and loaded from a
+// file with SaveCookies and LoadCookies.
+//
+// The jar belongs to the client's *http.Client; assigning a different
+// Client afterwards drops it.
+func WithCookieJar() Option {
+	return func(client *HTTPClient) {
+		client.Client.Jar = newCookieJar()
+	}
+}
+
+// cookieJar wraps a cookiejar.Jar, which can't enumerate its contents,
+// keeping its own record of every live cookie so the jar can be saved and
+// selectively cleared.
+type cookieJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries map[cookieKey]savedCookie
+	seq     int
+}
+
+// cookieKey identifies a cookie the way the jar does: a later cookie with
+// the same key replaces an earlier one.
+type cookieKey struct {
+	domain   string
+	hostOnly bool
+	path     string
+	name     string
+}
+
+// savedCookie is a cookie as written to disk, along with the URL that set
+// it. Max-Age is converted to an absolute Expires when the cookie is set.
+type savedCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  *time.Time    `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HTTPOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+
+	seq int
+}
+
+func newCookieJar() *cookieJar {
+	j := &cookieJar{entries: map[cookieKey]savedCookie{}}
+	j.jar, _ = cookiejar.New(nil) // only fails on invalid options
+	return j
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	now := time.Now()
+	for _, c := range cookies {
+		j.record(u, c, now)
+	}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// record notes a cookie set for u, or forgets it if c deletes it.
+func (j *cookieJar) record(u *url.URL, c *http.Cookie, now time.Time) {
+	key := cookieKey{
+		domain:   strings.ToLower(strings.TrimPrefix(c.Domain, ".")),
+		hostOnly: c.Domain == "",
+		path:     c.Path,
+		name:     c.Name,
+	}
+	if key.hostOnly {
+		key.domain = strings.ToLower(u.Hostname())
+	}
+	if !strings.HasPrefix(key.path, "/") {
+		key.path = defaultCookiePath(u)
+	}
+
+	var expires *time.Time
+	switch {
+	case c.MaxAge < 0:
+		delete(j.entries, key)
+		return
+	case c.MaxAge > 0:
+		t := now.Add(time.Duration(c.MaxAge) * time.Second)
+		expires = &t
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			delete(j.entries, key)
+			return
+		}
+		t := c.Expires
+		expires = &t
+	}
+
+	j.seq++
+	j.entries[key] = savedCookie{
+		URL:      u.String(),
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  expires,
+		Secure:   c.Secure,
+		HTTPOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+		seq:      j.seq,
+	}
+}
+
+// defaultCookiePath is the default-path of RFC 6265 section 5.1.4.
+func defaultCookiePath(u *url.URL) string {
+	i := strings.LastIndex(u.Path, "/")
+	if !strings.HasPrefix(u.Path, "/") || i == 0 {
+		return "/"
+	}
+	return u.Path[:i]
+}
+
+func (sc savedCookie) cookie() *http.Cookie {
+	c := &http.Cookie{
+		Name:     sc.Name,
+		Value:    sc.Value,
+		Domain:   sc.Domain,
+		Path:     sc.Path,
+		Secure:   sc.Secure,
+		HttpOnly: sc.HTTPOnly,
+		SameSite: sc.SameSite,
+	}
+	if sc.Expires != nil {
+		c.Expires = *sc.Expires
+	}
+	return c
+}
+
+// live returns the unexpired cookies in the order they were set.
+func (j *cookieJar) live(now time.Time) []savedCookie {
+	saved := make([]savedCookie, 0, len(j.entries))
+	for _, sc := range j.entries {
+		if sc.Expires == nil || sc.Expires.After(now) {
+			saved = append(saved, sc)
+		}
+	}
+	sort.Slice(saved, func(a, b int) bool { return saved[a].seq < saved[b].seq })
+	return saved
+}
+
+// restore sets saved cookies into the jar.
+func (j *cookieJar) restore(saved []savedCookie) error {
+	now := time.Now()
+	for _, sc := range saved {
+		if sc.Expires != nil && !sc.Expires.After(now) {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			return fmt.Errorf("cookie %s: %w", sc.Name, err)
+		}
+		c := sc.cookie()
+		j.jar.SetCookies(u, []*http.Cookie{c})
+		j.record(u, c, now)
+	}
+	return nil
+}
+
+// clear removes the cookies that would be sent to u, or every cookie if u is
+// nil, by rebuilding the jar without them.
+func (j *cookieJar) clear(u *url.URL) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var keep []savedCookie
+	if u != nil {
+		for _, sc := range j.live(time.Now()) {
+			if !sc.matches(u) {
+				keep = append(keep, sc)
+			}
+		}
+	}
+	j.jar, _ = cookiejar.New(nil)
+	j.entries = map[cookieKey]savedCookie{}
+	_ = j.restore(keep) // the URLs were parsed once already
+}
+
+func (sc savedCookie) matches(u *url.URL) bool {
+	origin, err := url.Parse(sc.URL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if sc.Domain == "" {
+		if host != strings.ToLower(origin.Hostname()) {
+			return false
+		}
+	} else {
+		domain := strings.ToLower(strings.TrimPrefix(sc.Domain, "."))
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			return false
+		}
+	}
+
+	path := sc.Path
+	if !strings.HasPrefix(path, "/") {
+		path = defaultCookiePath(origin)
+	}
+	reqPath := u.Path
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	return reqPath == path || strings.HasPrefix(reqPath, path) &&
+		(strings.HasSuffix(path, "/") || reqPath[len(path)] == '/')
+}
+
+// cookieURL resolves rawURL, which may be a path relative to BaseURL.
+func (client *HTTPClient) cookieURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err == nil && !u.IsAbs() {
+		client.mu.RLock()
+		baseURL := client.BaseURL
+		client.mu.RUnlock()
+		u, err = url.Parse(baseURL + rawURL)
+	}
+	return u, err
+}
+
+func (client *HTTPClient) jar() http.CookieJar {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	if client.Client == nil {
+		return nil
+	}
+	return client.Client.Jar
+}
+
+func (client *HTTPClient) cookieJar() (*cookieJar, error) {
+	if jar, ok := client.jar().(*cookieJar); ok {
+		return jar, nil
+	}
+	return nil, errNoCookieJar
+}
+
+// Cookies returns the cookies that would be sent to rawURL, which may be a
+// path relative to BaseURL.
+func (client *HTTPClient) Cookies(rawURL string) ([]*http.Cookie, error) {
+	jar := client.jar()
+	if jar == nil {
+		return nil, fmt.Errorf("simplehttp: cookies: %w", errNoCookieJar)
+	}
+	u, err := client.cookieURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("simplehttp: cookies: %w", err)
+	}
+	return jar.Cookies(u), nil
+}
+
+// SetCookies stores cookies as if they were set by a response from rawURL.
+func (client *HTTPClient) SetCookies(rawURL string, cookies ...*http.Cookie) error {
+	jar := client.jar()
+	if jar == nil {
+		return fmt.Errorf("simplehttp: setting cookies: %w", errNoCookieJar)
+	}
+	u, err := client.cookieURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("simplehttp: setting cookies: %w", err)
+	}
+	jar.SetCookies(u, cookies)
+	return nil
+}
+
+// ClearCookies removes the cookies that would be sent to rawURL, or every
+// cookie if rawURL is empty.
+func (client *HTTPClient) ClearCookies(rawURL string) error {
+	jar, err := client.cookieJar()
+	if err != nil {
+		return fmt.Errorf("simplehttp: clearing cookies: %w", err)
+	}
+	if rawURL == "" {
+		jar.clear(nil)
+		return nil
+	}
+	u, err := client.cookieURL(rawURL)
+	if err != nil {
+		return fmt.Errorf("simplehttp: clearing cookies: %w", err)
+	}
+	jar.clear(u)
+	return nil
+}
+
+// SaveCookies writes the jar's unexpired cookies, including session
+// cookies, to a JSON file readable only by its owner. The file is replaced
+// atomically.
+func (client *HTTPClient) SaveCookies(path string) error {
+	jar, err := client.cookieJar()
+	if err != nil {
+		return fmt.Errorf("simplehttp: saving cookies: %w", err)
+	}
+	jar.mu.Lock()
+	data, err := json.MarshalIndent(jar.live(time.Now()), "", "  ")
+	jar.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("simplehttp: saving cookies: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("simplehttp: saving cookies: %w", err)
+	}
+	return nil
+}
+
+// LoadCookies adds the cookies saved in path to the jar, skipping any that
+// have expired since.
+func (client *HTTPClient) LoadCookies(path string) error {
+	jar, err := client.cookieJar()
+	if err != nil {
+		return fmt.Errorf("simplehttp: loading cookies: %w", err)
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // path is caller-provided by design
+	if err != nil {
+		return fmt.Errorf("simplehttp: loading cookies: %w", err)
+	}
+	var saved []savedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("simplehttp: loading cookies from %s: %w", path, err)
+	}
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+	if err := jar.restore(saved); err != nil {
+		return fmt.Errorf("simplehttp: loading cookies from %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,175 @@
+package simplehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cookieNames(cookies []*http.Cookie) string {
+	names := make([]string, len(cookies))
+	for i, c := range cookies {
+		names[i] = c.Name + "=" + c.Value
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func newCookieServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "remember", Value: "yes", Path: "/", MaxAge: 3600})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+		default:
+			_, _ = w.Write([]byte(cookieNames(r.Cookies())))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newCookieClient keeps the client's jar while using the test server's TLS
+// transport.
+func newCookieClient(ts *httptest.Server) *HTTPClient {
+	c := New(ts.URL, WithCookieJar())
+	c.Client.Transport = ts.Client().Transport
+	return c
+}
+
+func TestCookieJar(t *testing.T) { //nolint:funlen // subtests for each jar operation
+	t.Parallel()
+	ts := newCookieServer(t)
+
+	t.Run("session cookies are kept", func(t *testing.T) {
+		t.Parallel()
+		c := newCookieClient(ts)
+		if _, err := c.Get("/login"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/me")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "remember=yes,session=abc" {
+			t.Errorf("expected both cookies, got %q", response.Body)
+		}
+
+		if _, err := c.Get("/logout"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cookies, err := c.Cookies("/me")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cookieNames(cookies); got != "remember=yes" {
+			t.Errorf("expected remember=yes after logout, got %q", got)
+		}
+	})
+
+	t.Run("set and clear per URL", func(t *testing.T) {
+		t.Parallel()
+		c := New("https://example.com", WithCookieJar())
+		if err := c.SetCookies("/api/", &http.Cookie{Name: "a", Value: "1", Path: "/api"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := c.SetCookies("/", &http.Cookie{Name: "b", Value: "2"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := c.SetCookies("https://other.example.org/", &http.Cookie{Name: "c", Value: "3"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		cookies, _ := c.Cookies("/api/items")
+		if got := cookieNames(cookies); got != "a=1,b=2" {
+			t.Errorf("expected a=1,b=2 for /api/items, got %q", got)
+		}
+		cookies, _ = c.Cookies("/other")
+		if got := cookieNames(cookies); got != "b=2" {
+			t.Errorf("expected b=2 for /other, got %q", got)
+		}
+
+		if err := c.ClearCookies("/api/items"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cookies, _ = c.Cookies("/api/items")
+		if len(cookies) != 0 {
+			t.Errorf("expected no cookies for /api/items, got %q", cookieNames(cookies))
+		}
+		cookies, _ = c.Cookies("https://other.example.org/")
+		if got := cookieNames(cookies); got != "c=3" {
+			t.Errorf("expected other host's cookie to survive, got %q", got)
+		}
+
+		if err := c.ClearCookies(""); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cookies, _ = c.Cookies("https://other.example.org/")
+		if len(cookies) != 0 {
+			t.Errorf("expected no cookies, got %q", cookieNames(cookies))
+		}
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "cookies.json")
+
+		c := newCookieClient(ts)
+		if _, err := c.Get("/login"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = c.SetCookies("/", &http.Cookie{Name: "stale", Value: "x", Expires: time.Now().Add(50 * time.Millisecond)})
+		if err := c.SaveCookies(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Errorf("expected mode 0600, got %o", perm)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		loaded := newCookieClient(ts)
+		if err := loaded.LoadCookies(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := loaded.Get("/me")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "remember=yes,session=abc" {
+			t.Errorf("expected restored cookies without the expired one, got %q", response.Body)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		c := New("https://example.com", WithCookieJar())
+		err := c.LoadCookies(filepath.Join(t.TempDir(), "absent.json"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected os.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("no jar", func(t *testing.T) {
+		t.Parallel()
+		c := New("https://example.com")
+		if _, err := c.Cookies("/"); !errors.Is(err, errNoCookieJar) {
+			t.Errorf("expected errNoCookieJar, got %v", err)
+		}
+		if err := c.SaveCookies(filepath.Join(t.TempDir(), "c.json")); !errors.Is(err, errNoCookieJar) {
+			t.Errorf("expected errNoCookieJar, got %v", err)
+		}
+	})
+}
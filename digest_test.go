@@ -0,0 +1,200 @@
+package simplehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDigestResponseVectors(t *testing.T) {
+	t.Parallel()
+
+	// RFC 7616 section 3.9.1
+	tests := []struct {
+		algorithm string
+		want      string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tt := range tests {
+		c := &digestChallenge{
+			realm:     "http-auth@example.org",
+			nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			opaque:    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			algorithm: tt.algorithm,
+			qop:       "auth",
+		}
+		got := c.response("Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", 1, "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		if got != tt.want {
+			t.Errorf("expected %s response %s, got %s", tt.algorithm, tt.want, got)
+		}
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	t.Parallel()
+
+	c, ok := parseDigestChallenge([]string{
+		`Basic realm="basic", Digest realm="r", qop="auth-int, auth", algorithm=MD5, nonce="n1", opaque="o"`,
+		`Digest realm="r", qop="auth", algorithm=SHA-256, nonce="n2", userhash=true`,
+	})
+	if !ok {
+		t.Fatal("expected a challenge")
+	}
+	if c.algorithm != "SHA-256" || c.nonce != "n2" || c.qop != "auth" || !c.userhash {
+		t.Errorf("expected the SHA-256 challenge, got %+v", c)
+	}
+
+	if _, ok := parseDigestChallenge([]string{`Digest realm="r", qop="auth-int", nonce="n"`}); ok {
+		t.Error("expected auth-int only challenge to be unusable")
+	}
+	if _, ok := parseDigestChallenge([]string{`Basic realm="r"`}); ok {
+		t.Error("expected no Digest challenge")
+	}
+}
+
+// digestServer checks Digest credentials the way a server would, issuing a
+// fresh nonce for each challenge and rejecting reused nonce counts.
+type digestServer struct {
+	*httptest.Server
+	algorithm string
+
+	mu         sync.Mutex
+	nonces     int
+	lastNC     map[string]uint32
+	challenges int
+	bodies     []string
+}
+
+func newDigestServer(t *testing.T, algorithm string) *digestServer {
+	t.Helper()
+	s := &digestServer{algorithm: algorithm, lastNC: map[string]uint32{}}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *digestServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+
+	if s.valid(r) {
+		s.bodies = append(s.bodies, string(body))
+		_, _ = fmt.Fprintf(w, "hello %s", r.URL.Path)
+		return
+	}
+	s.nonces++
+	s.challenges++
+	nonce := fmt.Sprintf("nonce-%d", s.nonces)
+	s.lastNC[nonce] = 0
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="test", qop="auth", algorithm=%s, nonce="%s", opaque="xyz"`, s.algorithm, nonce))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func (s *digestServer) valid(r *http.Request) bool {
+	ch := parseChallenges(r.Header.Get("Authorization"))
+	if len(ch) != 1 || ch[0].scheme != "Digest" {
+		return false
+	}
+	p := ch[0].params
+	last, ok := s.lastNC[p["nonce"]]
+	if !ok || p["opaque"] != "xyz" || p["uri"] != r.URL.RequestURI() {
+		return false
+	}
+	var nc uint32
+	if _, err := fmt.Sscanf(p["nc"], "%08x", &nc); err != nil || nc <= last {
+		return false
+	}
+	c := &digestChallenge{realm: "test", nonce: p["nonce"], algorithm: s.algorithm, qop: "auth"}
+	if p["response"] != c.response("user", "pass", r.Method, p["uri"], nc, p["cnonce"]) {
+		return false
+	}
+	s.lastNC[p["nonce"]] = nc
+	return true
+}
+
+func TestDigestAuth(t *testing.T) { //nolint:funlen // subtests for each algorithm and body kind
+	t.Parallel()
+
+	for _, alg := range []string{"MD5", "SHA-256", "SHA-256-sess"} {
+		alg := alg
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+			ts := newDigestServer(t, alg)
+			c := New(ts.URL)
+			c.Client = ts.Client()
+			c.Use(NewDigestAuth("user", "pass").Middleware())
+
+			for i := 0; i < 3; i++ {
+				response, err := c.Get("/a?x=1")
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if response.Code != http.StatusOK || response.Body != "hello /a" {
+					t.Errorf("expected 200 hello /a, got %d %q", response.Code, response.Body)
+				}
+			}
+			// later requests reuse the nonce with increasing counts
+			if ts.challenges != 1 {
+				t.Errorf("expected 1 challenge, got %d", ts.challenges)
+			}
+		})
+	}
+
+	t.Run("body is replayed", func(t *testing.T) {
+		t.Parallel()
+		ts := newDigestServer(t, "SHA-256")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(NewDigestAuth("user", "pass").Middleware())
+
+		response, err := c.R().JSON(map[string]string{"k": "v"}).Post("/upload")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+		if len(ts.bodies) != 1 || ts.bodies[0] != `{"k":"v"}` {
+			t.Errorf(`expected body {"k":"v"}, got %q`, ts.bodies)
+		}
+	})
+
+	t.Run("unreplayable body returns the challenge", func(t *testing.T) {
+		t.Parallel()
+		ts := newDigestServer(t, "SHA-256")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(NewDigestAuth("user", "pass").Middleware())
+
+		response, err := c.R().BodyReader(io.NopCloser(strings.NewReader("data")), -1).Post("/upload")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		t.Parallel()
+		ts := newDigestServer(t, "MD5")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(NewDigestAuth("user", "wrong").Middleware())
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusUnauthorized || ts.challenges != 2 {
+			t.Errorf("expected a single retry ending in 401, got %d after %d challenges", response.Code, ts.challenges)
+		}
+	})
+}
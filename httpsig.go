@@ -0,0 +1,643 @@
+package simplehttp
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSignatureLabel = "sig1"
+	rsaPSSSaltLength      = 64
+)
+
+// ErrInvalidSignature is returned, wrapped, when an HTTP message signature
+// is missing, malformed or doesn't verify.
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+var defaultSignatureComponents = []string{"@method", "@target-uri"}
+
+// MessageSignerConfig configures RFC 9421 HTTP message signatures.
+type MessageSignerConfig struct {
+	KeyID string
+	// Key is a []byte shared secret (hmac-sha256), an ed25519.PrivateKey
+	// (ed25519) or an *rsa.PrivateKey (rsa-pss-sha512).
+	Key any
+	// Label names the signature in the Signature and Signature-Input
+	// headers; it defaults to "sig1".
+	Label string
+	// Components lists the covered components, such as "@method",
+	// "@authority", "@path" or lower-case header names. It defaults to
+	// "@method" and "@target-uri".
+	Components []string
+	// Expires, if set, adds an expires parameter this far after created.
+	Expires time.Duration
+	Tag     string
+	// IncludeAlg adds the alg parameter naming the algorithm.
+	IncludeAlg bool
+}
+
+// MessageSigner signs outbound requests per RFC 9421.
+type MessageSigner struct {
+	config     MessageSignerConfig
+	alg        sigAlgorithm
+	components []sigComponent
+	now        func() time.Time
+}
+
+func NewMessageSigner(config MessageSignerConfig) (*MessageSigner, error) {
+	alg, err := signingAlgorithm(config.Key)
+	if err != nil {
+		return nil, err
+	}
+	if config.Label == "" {
+		config.Label = defaultSignatureLabel
+	}
+	if config.Components == nil {
+		config.Components = defaultSignatureComponents
+	}
+	components, err := parseComponents(config.Components)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageSigner{config: config, alg: alg, components: components, now: time.Now}, nil
+}
+
+// Middleware signs every request just before it is sent.
+func (s *MessageSigner) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			if err := s.Sign(req); err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// Sign adds Signature-Input and Signature headers to req. Signatures already
+// on the request are kept, so several signers can be chained.
+func (s *MessageSigner) Sign(req *http.Request) error {
+	return s.signMessage(sigMessage{req: req}, req.Header)
+}
+
+func (s *MessageSigner) signMessage(m sigMessage, h http.Header) error {
+	created := s.now().Unix()
+	var params strings.Builder
+	params.WriteString(serializeComponents(s.components))
+	fmt.Fprintf(&params, ";created=%d", created)
+	if s.config.Expires > 0 {
+		fmt.Fprintf(&params, ";expires=%d", created+int64(s.config.Expires/time.Second))
+	}
+	if s.config.KeyID != "" {
+		params.WriteString(";keyid=" + quoteString(s.config.KeyID))
+	}
+	if s.config.IncludeAlg {
+		params.WriteString(";alg=" + quoteString(s.alg.name))
+	}
+	if s.config.Tag != "" {
+		params.WriteString(";tag=" + quoteString(s.config.Tag))
+	}
+
+	base, err := signatureBase(m, s.components, params.String())
+	if err != nil {
+		return fmt.Errorf("httpsig: %w", err)
+	}
+	sig, err := s.alg.sign([]byte(base))
+	if err != nil {
+		return fmt.Errorf("httpsig: signing: %w", err)
+	}
+	h.Add("Signature-Input", s.config.Label+"="+params.String())
+	h.Add("Signature", s.config.Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// MessageVerifierConfig configures verification of RFC 9421 signatures.
+type MessageVerifierConfig struct {
+	// Key is a []byte shared secret (hmac-sha256), an ed25519.PublicKey
+	// (ed25519) or an *rsa.PublicKey (rsa-pss-sha512).
+	Key any
+	// KeyID, if set, selects the signature with this keyid parameter.
+	KeyID string
+	// Label, if set, selects the signature with this label. With neither
+	// KeyID nor Label, the first signature is verified.
+	Label string
+	// RequiredComponents must all be covered by the signature.
+	RequiredComponents []string
+	// MaxAge, if set, rejects signatures created longer ago than this.
+	MaxAge time.Duration
+}
+
+// MessageVerifier verifies RFC 9421 signatures on responses, or on requests
+// received by a server.
+type MessageVerifier struct {
+	config   MessageVerifierConfig
+	alg      sigAlgorithm
+	required []sigComponent
+	now      func() time.Time
+}
+
+func NewMessageVerifier(config MessageVerifierConfig) (*MessageVerifier, error) {
+	alg, err := verifyingAlgorithm(config.Key)
+	if err != nil {
+		return nil, err
+	}
+	required, err := parseComponents(config.RequiredComponents)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageVerifier{config: config, alg: alg, required: required, now: time.Now}, nil
+}
+
+// Middleware rejects responses whose signature doesn't verify, returning an
+// error wrapping ErrInvalidSignature.
+func (v *MessageVerifier) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			response, err := next.Do(req)
+			if err != nil {
+				return response, err
+			}
+			if err := v.VerifyResponse(response); err != nil {
+				drain(response)
+				return nil, err
+			}
+			return response, nil
+		})
+	}
+}
+
+// VerifyResponse checks the signature on resp. Components marked ;req are
+// taken from resp.Request.
+func (v *MessageVerifier) VerifyResponse(resp *http.Response) error {
+	return v.verify(sigMessage{req: resp.Request, resp: resp}, resp.Header)
+}
+
+// VerifyRequest checks the signature on a request, typically one received by
+// a server.
+func (v *MessageVerifier) VerifyRequest(req *http.Request) error {
+	return v.verify(sigMessage{req: req}, req.Header)
+}
+
+func (v *MessageVerifier) verify(m sigMessage, h http.Header) error {
+	inputs, err := parseSignatureInput(strings.Join(h.Values("Signature-Input"), ","))
+	if err != nil {
+		return fmt.Errorf("%w: Signature-Input: %v", ErrInvalidSignature, err)
+	}
+	input, ok := v.selectInput(inputs)
+	if !ok {
+		return fmt.Errorf("%w: no matching signature", ErrInvalidSignature)
+	}
+	sig, ok := parseSignatures(h.Values("Signature"))[input.label]
+	if !ok {
+		return fmt.Errorf("%w: no Signature for label %q", ErrInvalidSignature, input.label)
+	}
+
+	if alg, ok := input.params["alg"]; ok && alg != v.alg.name {
+		return fmt.Errorf("%w: algorithm %q, expected %q", ErrInvalidSignature, alg, v.alg.name)
+	}
+	for _, c := range v.required {
+		if !input.covers(c) {
+			return fmt.Errorf("%w: component %s is not covered", ErrInvalidSignature, c)
+		}
+	}
+	now := v.now()
+	if expires, ok := input.params["expires"]; ok {
+		t, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || now.Unix() > t {
+			return fmt.Errorf("%w: signature expired", ErrInvalidSignature)
+		}
+	}
+	if v.config.MaxAge > 0 {
+		t, err := strconv.ParseInt(input.params["created"], 10, 64)
+		if err != nil || now.Sub(time.Unix(t, 0)) > v.config.MaxAge {
+			return fmt.Errorf("%w: signature too old", ErrInvalidSignature)
+		}
+	}
+
+	base, err := signatureBase(m, input.components, input.raw)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if err := v.alg.verify([]byte(base), sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+func (v *MessageVerifier) selectInput(inputs []sigInput) (sigInput, bool) {
+	for _, in := range inputs {
+		if v.config.Label != "" && in.label != v.config.Label {
+			continue
+		}
+		if v.config.KeyID != "" && in.params["keyid"] != v.config.KeyID {
+			continue
+		}
+		return in, true
+	}
+	return sigInput{}, false
+}
+
+// sigAlgorithm is one of the RFC 9421 signature algorithms.
+type sigAlgorithm struct {
+	name   string
+	sign   func(base []byte) ([]byte, error)
+	verify func(base, sig []byte) error
+}
+
+func hmacAlgorithm(secret []byte) sigAlgorithm {
+	mac := func(base []byte) []byte {
+		h := hmac.New(sha256.New, secret)
+		h.Write(base)
+		return h.Sum(nil)
+	}
+	return sigAlgorithm{
+		name: "hmac-sha256",
+		sign: func(base []byte) ([]byte, error) { return mac(base), nil },
+		verify: func(base, sig []byte) error {
+			if !hmac.Equal(mac(base), sig) {
+				return errors.New("hmac mismatch")
+			}
+			return nil
+		},
+	}
+}
+
+func signingAlgorithm(key any) (sigAlgorithm, error) {
+	switch k := key.(type) {
+	case []byte:
+		return hmacAlgorithm(k), nil
+	case ed25519.PrivateKey:
+		return sigAlgorithm{name: "ed25519", sign: func(base []byte) ([]byte, error) {
+			return ed25519.Sign(k, base), nil
+		}}, nil
+	case *rsa.PrivateKey:
+		return sigAlgorithm{name: "rsa-pss-sha512", sign: func(base []byte) ([]byte, error) {
+			digest := sha512.Sum512(base)
+			return rsa.SignPSS(rand.Reader, k, crypto.SHA512, digest[:], &rsa.PSSOptions{SaltLength: rsaPSSSaltLength})
+		}}, nil
+	default:
+		return sigAlgorithm{}, fmt.Errorf("httpsig: unsupported signing key type %T", key)
+	}
+}
+
+func verifyingAlgorithm(key any) (sigAlgorithm, error) {
+	switch k := key.(type) {
+	case []byte:
+		return hmacAlgorithm(k), nil
+	case ed25519.PublicKey:
+		return sigAlgorithm{name: "ed25519", verify: func(base, sig []byte) error {
+			if !ed25519.Verify(k, base, sig) {
+				return errors.New("ed25519 verification failed")
+			}
+			return nil
+		}}, nil
+	case *rsa.PublicKey:
+		return sigAlgorithm{name: "rsa-pss-sha512", verify: func(base, sig []byte) error {
+			digest := sha512.Sum512(base)
+			return rsa.VerifyPSS(k, crypto.SHA512, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+		}}, nil
+	default:
+		return sigAlgorithm{}, fmt.Errorf("httpsig: unsupported verifying key type %T", key)
+	}
+}
+
+// sigComponent is a covered component: a derived component such as
+// "@method" or a lower-case header name, optionally taken from the request
+// that produced a response (the ;req parameter).
+type sigComponent struct {
+	name string
+	req  bool
+}
+
+func (c sigComponent) String() string {
+	if c.req {
+		return quoteString(c.name) + ";req"
+	}
+	return quoteString(c.name)
+}
+
+// parseComponents parses identifiers written as in the configs, e.g.
+// "content-type" or "@method;req".
+func parseComponents(ids []string) ([]sigComponent, error) {
+	components := make([]sigComponent, 0, len(ids))
+	for _, id := range ids {
+		name, param, hasParam := strings.Cut(id, ";")
+		if hasParam && param != "req" {
+			return nil, fmt.Errorf("httpsig: unsupported component parameter %q", param)
+		}
+		components = append(components, sigComponent{name: strings.ToLower(name), req: hasParam})
+	}
+	return components, nil
+}
+
+func serializeComponents(components []sigComponent) string {
+	items := make([]string, len(components))
+	for i, c := range components {
+		items[i] = c.String()
+	}
+	return "(" + strings.Join(items, " ") + ")"
+}
+
+// sigMessage is the message being signed or verified: a request, or a
+// response along with the request that produced it.
+type sigMessage struct {
+	req  *http.Request
+	resp *http.Response
+}
+
+// signatureBase builds the signature base of RFC 9421 section 2.5.
+func signatureBase(m sigMessage, components []sigComponent, params string) (string, error) {
+	var b strings.Builder
+	seen := map[sigComponent]bool{}
+	for _, c := range components {
+		if seen[c] {
+			return "", fmt.Errorf("component %s covered twice", c)
+		}
+		seen[c] = true
+		value, err := m.value(c)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(c.String() + ": " + value + "\n")
+	}
+	b.WriteString(`"@signature-params": ` + params)
+	return b.String(), nil
+}
+
+func (m sigMessage) value(c sigComponent) (string, error) {
+	if c.req && m.resp == nil {
+		return "", fmt.Errorf("component %s: ;req is only valid on responses", c)
+	}
+	if m.req == nil && (c.req || m.resp == nil) {
+		return "", fmt.Errorf("component %s: no request", c)
+	}
+	fromResponse := m.resp != nil && !c.req
+
+	if !strings.HasPrefix(c.name, "@") {
+		h := m.req.Header
+		if fromResponse {
+			h = m.resp.Header
+		}
+		values := h.Values(c.name)
+		if len(values) == 0 && c.name == "content-length" && !fromResponse && m.req.ContentLength > 0 {
+			values = []string{strconv.FormatInt(m.req.ContentLength, 10)}
+		}
+		if len(values) == 0 {
+			return "", fmt.Errorf("component %s: header not present", c)
+		}
+		// values may be the header's own slice, so trim a copy
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.TrimSpace(v)
+		}
+		return strings.Join(trimmed, ", "), nil
+	}
+
+	if c.name == "@status" {
+		if !fromResponse {
+			return "", fmt.Errorf("component %s is only valid on responses", c)
+		}
+		return strconv.Itoa(m.resp.StatusCode), nil
+	}
+	if fromResponse {
+		return "", fmt.Errorf("component %s needs ;req on a response", c)
+	}
+	req := m.req
+	switch c.name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return sigScheme(req) + "://" + sigAuthority(req) + req.URL.RequestURI(), nil
+	case "@authority":
+		return sigAuthority(req), nil
+	case "@scheme":
+		return sigScheme(req), nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if p := req.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	default:
+		return "", fmt.Errorf("unsupported component %s", c)
+	}
+}
+
+func sigScheme(req *http.Request) string {
+	if req.URL.Scheme != "" {
+		return strings.ToLower(req.URL.Scheme)
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// sigAuthority is the request's host, lower-cased and without a default port.
+func sigAuthority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+	switch scheme := sigScheme(req); {
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+// quoteString writes s as a quoted string, escaping backslashes and quotes.
+func quoteString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sigInput is one member of the Signature-Input dictionary. raw is its
+// serialized value, which is the @signature-params line of the base.
+type sigInput struct {
+	label      string
+	components []sigComponent
+	params     map[string]string
+	raw        string
+}
+
+func (in sigInput) covers(c sigComponent) bool {
+	for _, covered := range in.components {
+		if covered == c {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSignatureInput parses the Signature-Input structured field, keeping
+// members in order.
+func parseSignatureInput(s string) ([]sigInput, error) {
+	p := &sfParser{s: s}
+	var inputs []sigInput
+	for {
+		p.skipSpace()
+		if p.done() {
+			return inputs, nil
+		}
+		label := p.token()
+		if label == "" || !p.consume('=') {
+			return nil, errors.New("expected label=")
+		}
+		start := p.i
+		if !p.consume('(') {
+			return nil, fmt.Errorf("signature %q: expected inner list", label)
+		}
+		in := sigInput{label: label}
+		for {
+			p.skipSpace()
+			if p.consume(')') {
+				break
+			}
+			name, ok := p.quoted()
+			if !ok {
+				return nil, fmt.Errorf("signature %q: expected component name", label)
+			}
+			params, err := p.params()
+			if err != nil {
+				return nil, fmt.Errorf("signature %q: %w", label, err)
+			}
+			c := sigComponent{name: name}
+			for k := range params {
+				if k != "req" {
+					return nil, fmt.Errorf("signature %q: unsupported component parameter %q", label, k)
+				}
+				c.req = true
+			}
+			in.components = append(in.components, c)
+		}
+		params, err := p.params()
+		if err != nil {
+			return nil, fmt.Errorf("signature %q: %w", label, err)
+		}
+		in.params = params
+		in.raw = p.s[start:p.i]
+		inputs = append(inputs, in)
+
+		p.skipSpace()
+		if !p.done() && !p.consume(',') {
+			return nil, fmt.Errorf("signature %q: expected ','", label)
+		}
+	}
+}
+
+// parseSignatures parses the Signature structured field into raw signature
+// bytes by label, skipping members that aren't byte sequences.
+func parseSignatures(values []string) map[string][]byte {
+	sigs := map[string][]byte{}
+	for _, v := range values {
+		for _, member := range strings.Split(v, ",") {
+			label, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+			if !ok || !strings.HasPrefix(value, ":") {
+				continue
+			}
+			value, _, _ = strings.Cut(value[1:], ":")
+			if sig, err := base64.StdEncoding.DecodeString(value); err == nil {
+				sigs[label] = sig
+			}
+		}
+	}
+	return sigs
+}
+
+// sfParser reads the subset of RFC 8941 structured fields used by
+// Signature-Input.
+type sfParser struct {
+	s string
+	i int
+}
+
+func (p *sfParser) done() bool { return p.i >= len(p.s) }
+
+func (p *sfParser) skipSpace() {
+	for !p.done() && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if !p.done() && p.s[p.i] == c {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// token reads a key, bare token or integer.
+func (p *sfParser) token() string {
+	start := p.i
+	for !p.done() && !strings.ContainsRune(`=;,() "`, rune(p.s[p.i])) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *sfParser) quoted() (string, bool) {
+	if !p.consume('"') {
+		return "", false
+	}
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '"':
+			return b.String(), true
+		case c == '\\' && !p.done():
+			b.WriteByte(p.s[p.i])
+			p.i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", false
+}
+
+// params reads ;key[=value] parameters; a bare key is boolean true.
+func (p *sfParser) params() (map[string]string, error) {
+	params := map[string]string{}
+	for p.consume(';') {
+		p.skipSpace()
+		key := p.token()
+		if key == "" {
+			return nil, errors.New("expected parameter name")
+		}
+		value := "?1"
+		if p.consume('=') {
+			if !p.done() && p.s[p.i] == '"' {
+				v, ok := p.quoted()
+				if !ok {
+					return nil, fmt.Errorf("parameter %q: unterminated string", key)
+				}
+				value = v
+			} else {
+				value = p.token()
+			}
+		}
+		params[key] = value
+	}
+	return params, nil
+}
@@ -0,0 +1,35 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// JSON decodes the response body into v. An empty body leaves v untouched.
+func (resp HTTPResponse) JSON(v any) error {
+	if len(resp.Body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(resp.Body), v); err != nil {
+		return fmt.Errorf("simplehttp: %s %s: decoding response body: %w", resp.Method, resp.Path, err)
+	}
+	return nil
+}
+
+// GetJSON sends a GET request and decodes the response body into a T.
+func GetJSON[T any](client *HTTPClient, path string) (T, HTTPResponse, error) {
+	return SendJSON[T](client.R(), http.MethodGet, path)
+}
+
+// SendJSON sends r and decodes the response body into a T. Non-2xx responses
+// are decoded too; check HTTPResponse.Code before trusting the value.
+func SendJSON[T any](r *Request, method, path string) (T, HTTPResponse, error) {
+	var v T
+	resp, err := r.Send(method, path)
+	if err != nil {
+		return v, resp, err
+	}
+	err = resp.JSON(&v)
+	return v, resp, err
+}
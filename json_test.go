@@ -0,0 +1,88 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type joke struct {
+	ID     string `json:"id"`
+	Joke   string `json:"joke"`
+	Status int    `json:"status"`
+}
+
+func TestJSONDecoding(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.Client = ts.Client()
+
+	t.Run("GetJSON", func(t *testing.T) {
+		got, response, err := GetJSON[joke](c, "/icanhazdadjoke")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+		want := joke{ID: "JokeID", Joke: "JokeText", Status: 200}
+		if got != want {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("SendJSON", func(t *testing.T) {
+		got, _, err := SendJSON[map[string][]string](c.R().Header("X-Probe", "1"), http.MethodGet, "/header")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got["X-Probe"]) != 1 || got["X-Probe"][0] != "1" {
+			t.Errorf("expected X-Probe header to be echoed, got %v", got)
+		}
+	})
+
+	t.Run("ResponseJSON", func(t *testing.T) {
+		response, err := c.Get("/json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var got struct {
+			Name string `json:"name"`
+		}
+		if err := response.JSON(&got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Name != "roc" {
+			t.Errorf("expected name %q, got %q", "roc", got.Name)
+		}
+	})
+
+	t.Run("EmptyBody", func(t *testing.T) {
+		got, _, err := GetJSON[*joke](c, "/bad-request")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != nil {
+			t.Errorf("expected nil value for empty body, got %+v", got)
+		}
+	})
+
+	t.Run("DecodeError", func(t *testing.T) {
+		_, _, err := GetJSON[joke](c, "/host-header")
+		if err == nil {
+			t.Fatal("expected decode error, got nil")
+		}
+		if !strings.HasPrefix(err.Error(), "simplehttp: GET /host-header: decoding response body:") {
+			t.Errorf("expected wrapped decode error, got: %v", err)
+		}
+		var syntaxErr *json.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("expected error to wrap the decoder error, got: %v", err)
+		}
+	})
+}
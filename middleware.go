@@ -0,0 +1,48 @@
+package simplehttp
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Doer sends a request and returns its response; *http.Client satisfies it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// DoerFunc adapts a function to the Doer interface.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a Doer to act on requests before they are sent and on
+// responses before their body is read.
+type Middleware func(next Doer) Doer
+
+var errNoResponse = errors.New("middleware returned neither a response nor an error")
+
+// Use appends middleware to the client's chain. The first middleware
+// registered is the outermost: it sees the request first and the response
+// last. The chain wraps the client's retries, circuit breaker and rate
+// limits, so middleware sees one call however many attempts it takes.
+func (client *HTTPClient) Use(middleware ...Middleware) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	chain := make([]Middleware, 0, len(client.middleware)+len(middleware))
+	chain = append(chain, client.middleware...)
+	client.middleware = append(chain, middleware...)
+}
+
+// chain builds the Doer for one call, with the client's middleware wrapped
+// around its built-in pipeline.
+func (client snapshot) chain(info *callInfo) Doer {
+	var doer Doer = DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return client.send(req, info)
+	})
+	for i := len(client.middleware) - 1; i >= 0; i-- {
+		doer = client.middleware[i](doer)
+	}
+	return doer
+}
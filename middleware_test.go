@@ -0,0 +1,141 @@
+package simplehttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMiddleware(t *testing.T) { //nolint:funlen // subtests for each middleware use
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+
+	t.Run("Order", func(t *testing.T) {
+		var mu sync.Mutex
+		var calls []string
+		trace := func(name string) Middleware {
+			return func(next Doer) Doer {
+				return DoerFunc(func(req *http.Request) (*http.Response, error) {
+					mu.Lock()
+					calls = append(calls, name+" before")
+					mu.Unlock()
+					resp, err := next.Do(req)
+					mu.Lock()
+					calls = append(calls, name+" after")
+					mu.Unlock()
+					return resp, err
+				})
+			}
+		}
+
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(trace("outer"))
+		c.Use(trace("inner"))
+
+		if _, err := c.Get("/icanhazdadjoke"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"outer before", "inner before", "inner after", "outer after"}
+		if !cmp.Equal(want, calls) {
+			t.Error(cmp.Diff(want, calls))
+		}
+	})
+
+	t.Run("ModifiesRequest", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("Authorization", "Bearer goodtoken")
+				return next.Do(req)
+			})
+		})
+
+		response, err := c.Get("/protected")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "good" {
+			t.Errorf("expected body %q, got %q", "good", response.Body)
+		}
+	})
+
+	t.Run("ModifiesResponse", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Use(func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := next.Do(req)
+				if err != nil {
+					return nil, err
+				}
+				b, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				resp.Body = io.NopCloser(strings.NewReader(strings.ToUpper(string(b))))
+				return resp, nil
+			})
+		})
+
+		response, err := c.Get("/protected")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "BAD" {
+			t.Errorf("expected body %q, got %q", "BAD", response.Body)
+		}
+	})
+
+	t.Run("ShortCircuit", func(t *testing.T) {
+		errBlocked := errors.New("blocked")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		var body io.ReadCloser
+		c.Use(func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				body = req.Body
+				return nil, errBlocked
+			})
+		})
+
+		_, err := c.Get("/")
+		if !errors.Is(err, errBlocked) {
+			t.Errorf("expected middleware error, got: %v", err)
+		}
+		if !strings.HasPrefix(err.Error(), "simplehttp: GET /:") {
+			t.Errorf("expected wrapped error, got: %v", err)
+		}
+
+		// the unsent multipart body is closed, stopping its writer
+		_, _ = c.R().FileBytes("file", "a.txt", []byte("x")).Post("/")
+		if _, err := body.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the request body to be closed, got: %v", err)
+		}
+	})
+
+	t.Run("NoResponse", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		var body io.ReadCloser
+		c.Use(func(Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				body = req.Body
+				return nil, nil
+			})
+		})
+
+		if _, err := c.R().FileBytes("file", "a.txt", []byte("x")).Post("/"); !errors.Is(err, errNoResponse) {
+			t.Errorf("expected errNoResponse, got: %v", err)
+		}
+		if _, err := body.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected the request body to be closed, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type multipartEcho struct {
+	Values map[string][]string `json:"values"`
+	Files  map[string][]struct {
+		Filename string              `json:"Filename"`
+		Header   map[string][]string `json:"Header"`
+		Size     int64               `json:"Size"`
+	} `json:"files"`
+}
+
+func TestMultipartBody(t *testing.T) { //nolint:funlen // subtests for each file source
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("from disk"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("FileAndFields", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Data["owner"] = "ci"
+
+		response, err := c.R().
+			Form(url.Values{"tag": {"a", "b"}}).
+			File("file", path).
+			FileBytes("image", "pixel.png", []byte{0x89, 'P', 'N', 'G'}).
+			Post("/multipart")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got multipartEcho
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		wantValues := map[string][]string{"owner": {"ci"}, "tag": {"a", "b"}}
+		if !cmp.Equal(wantValues, got.Values) {
+			t.Error(cmp.Diff(wantValues, got.Values))
+		}
+		if f := got.Files["file"]; len(f) != 1 || f[0].Filename != "notes.txt" || f[0].Size != 9 {
+			t.Errorf("unexpected file part: %+v", f)
+		} else if ct := f[0].Header["Content-Type"]; len(ct) != 1 || !strings.HasPrefix(ct[0], "text/plain") {
+			t.Errorf("expected text/plain part, got %v", ct)
+		}
+		if f := got.Files["image"]; len(f) != 1 || f[0].Header["Content-Type"][0] != "image/png" {
+			t.Errorf("unexpected image part: %+v", f)
+		}
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().FileReader("file", "stream.bin", strings.NewReader("streamed")).Post("/file-text")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "streamed" {
+			t.Errorf("expected body %q, got %q", "streamed", response.Body)
+		}
+	})
+
+	t.Run("OverridesClientContentType", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Headers["Content-Type"] = "application/json"
+
+		response, err := c.R().FileBytes("file", "a.txt", []byte("bytes")).Post("/file-text")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "bytes" {
+			t.Errorf("expected body %q, got %q", "bytes", response.Body)
+		}
+	})
+
+	t.Run("EmptyClientEncoding", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBodyEncoding(EncodeMultipart)
+
+		response, err := c.Get("/content-type")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "" {
+			t.Errorf("expected no body or Content-Type without fields or files, got %q", response.Body)
+		}
+	})
+
+	t.Run("ExplicitContentType", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().Attach(MultipartFile{
+			Field:       "file",
+			Filename:    "data",
+			ContentType: "application/vnd.custom",
+			Data:        []byte("x"),
+		}).Post("/multipart")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got multipartEcho
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if f := got.Files["file"]; len(f) != 1 || f[0].Header["Content-Type"][0] != "application/vnd.custom" {
+			t.Errorf("unexpected file part: %+v", f)
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		_, err := c.R().File("file", filepath.Join(t.TempDir(), "missing")).Post("/multipart")
+		if err == nil {
+			t.Fatal("expected error for missing file, got nil")
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected not-exist error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package simplehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultExpiryDelta = 10 * time.Second
+
+// OAuth2Config describes a token endpoint and the client's credentials for
+// it. Tokens are fetched with the client_credentials grant, or with the
+// refresh_token grant once a refresh token is known. A refresh token the
+// server rejects as invalid_grant is dropped, and the client_credentials
+// grant used instead; after other failures it's tried again.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// RefreshToken, if set, is exchanged for access tokens instead of using
+	// the client_credentials grant. Without a ClientID or ClientSecret there
+	// is nothing to fall back on once the server rejects it.
+	RefreshToken string
+	// CredentialsInBody sends the client ID and secret as form fields rather
+	// than with HTTP Basic auth, for servers that require it.
+	CredentialsInBody bool
+	// ExpiryDelta refreshes tokens this long before they expire; it defaults
+	// to 10s.
+	ExpiryDelta time.Duration
+	// HTTPClient sends the token requests; it defaults to a client with the
+	// same 10s timeout New uses.
+	HTTPClient *http.Client
+}
+
+// OAuth2Token is an access token issued by the token endpoint. A zero Expiry
+// means the token doesn't expire.
+type OAuth2Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+func (t OAuth2Token) valid(delta time.Duration) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(delta).Before(t.Expiry))
+}
+
+func (t OAuth2Token) header() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer " + t.AccessToken
+	}
+	return t.TokenType + " " + t.AccessToken
+}
+
+// OAuth2 fetches, caches and refreshes access tokens. It's safe for
+// concurrent use: callers needing a token while one is being fetched wait
+// for that fetch rather than starting their own.
+type OAuth2 struct {
+	config OAuth2Config
+
+	mu       sync.Mutex
+	token    OAuth2Token
+	fetching chan struct{}
+	err      error
+}
+
+func NewOAuth2(config OAuth2Config) *OAuth2 {
+	if config.ExpiryDelta <= 0 {
+		config.ExpiryDelta = defaultExpiryDelta
+	}
+	return &OAuth2{config: config, token: OAuth2Token{RefreshToken: config.RefreshToken}}
+}
+
+// Token returns a valid access token, fetching a new one if the cached token
+// is missing or about to expire.
+func (o *OAuth2) Token(ctx context.Context) (OAuth2Token, error) {
+	for {
+		o.mu.Lock()
+		if o.token.valid(o.config.ExpiryDelta) {
+			token := o.token
+			o.mu.Unlock()
+			return token, nil
+		}
+		if o.fetching == nil {
+			o.fetching = make(chan struct{})
+			go o.fetch(o.fetching, o.token.RefreshToken)
+		}
+		fetching := o.fetching
+		o.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return OAuth2Token{}, ctx.Err()
+		case <-fetching:
+		}
+
+		// a freshly fetched token is used even if it expires within
+		// ExpiryDelta, rather than fetching again in a loop
+		o.mu.Lock()
+		token, err := o.token, o.err
+		o.mu.Unlock()
+		if err != nil {
+			return OAuth2Token{}, err
+		}
+		if token.AccessToken != "" {
+			return token, nil
+		}
+	}
+}
+
+// Invalidate discards the cached access token if it is still token, so the
+// next call to Token fetches a fresh one.
+func (o *OAuth2) Invalidate(token OAuth2Token) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token.AccessToken == token.AccessToken {
+		o.token.AccessToken = ""
+	}
+}
+
+// fetch runs detached from any caller's context, so one caller giving up
+// doesn't fail the fetch for everyone waiting on it; the client timeout
+// bounds it instead.
+func (o *OAuth2) fetch(done chan struct{}, refreshToken string) {
+	token, err := o.requestToken(refreshToken)
+	if err != nil && refreshToken != "" {
+		// the refresh token has expired or been revoked; retrying it would
+		// fail the same way, so drop it for the client's credentials. Other
+		// failures may be transient, so the token is kept for next time.
+		var tokenErr *tokenError
+		if errors.As(err, &tokenErr) && tokenErr.status == http.StatusBadRequest && tokenErr.code == "invalid_grant" {
+			refreshToken = ""
+			if o.config.ClientID != "" || o.config.ClientSecret != "" {
+				token, err = o.requestToken("")
+			}
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.err = err
+	if err == nil {
+		if token.RefreshToken == "" {
+			token.RefreshToken = refreshToken
+		}
+		o.token = token
+	} else {
+		o.token.RefreshToken = refreshToken
+	}
+	o.fetching = nil
+	close(done)
+}
+
+type tokenResponse struct {
+	AccessToken      string          `json:"access_token"`
+	TokenType        string          `json:"token_type"`
+	RefreshToken     string          `json:"refresh_token"`
+	ExpiresIn        json.RawMessage `json:"expires_in"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+// tokenError is an error response from the token endpoint.
+type tokenError struct {
+	status      int
+	code        string
+	description string
+}
+
+func (e *tokenError) Error() string {
+	msg := strings.TrimSpace(e.code + ": " + e.description)
+	return fmt.Sprintf("oauth2: token endpoint returned %d: %s", e.status, strings.Trim(msg, ": "))
+}
+
+func (o *OAuth2) requestToken(refreshToken string) (OAuth2Token, error) {
+	form := url.Values{}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(o.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.config.Scopes, " "))
+	}
+
+	tc := New("")
+	if o.config.HTTPClient != nil {
+		tc.Client = o.config.HTTPClient
+	}
+	r := tc.R().Header("Accept", contentTypeJSON)
+	if o.config.CredentialsInBody {
+		form.Set("client_id", o.config.ClientID)
+		form.Set("client_secret", o.config.ClientSecret)
+	} else {
+		// RFC 6749 section 2.3.1 form-encodes the credentials first
+		r.BasicAuth(url.QueryEscape(o.config.ClientID), url.QueryEscape(o.config.ClientSecret))
+	}
+
+	issued := time.Now()
+	resp, err := r.Form(form).Post(o.config.TokenURL)
+	if err != nil {
+		return OAuth2Token{}, fmt.Errorf("oauth2: fetching token: %w", err)
+	}
+
+	var body tokenResponse
+	if err := resp.JSON(&body); err != nil {
+		return OAuth2Token{}, fmt.Errorf("oauth2: token endpoint returned %d: %w", resp.Code, err)
+	}
+	if resp.Code != http.StatusOK || body.Error != "" {
+		return OAuth2Token{}, &tokenError{status: resp.Code, code: body.Error, description: body.ErrorDescription}
+	}
+	if body.AccessToken == "" {
+		return OAuth2Token{}, errors.New("oauth2: token endpoint returned no access_token")
+	}
+
+	token := OAuth2Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+	}
+	// expires_in is a number per the RFC, but some servers send a string
+	if secs, err := strconv.ParseInt(strings.Trim(string(body.ExpiresIn), `"`), 10, 64); err == nil && secs > 0 {
+		token.Expiry = issued.Add(time.Duration(secs) * time.Second)
+	}
+	return token, nil
+}
+
+// Middleware authenticates every request with a current access token. A 401
+// response is retried once with a freshly fetched token, provided the
+// request body can be replayed.
+func (o *OAuth2) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			token, err := o.Token(req.Context())
+			if err != nil {
+				closeBody(req)
+				return nil, err
+			}
+			retry := req.Clone(req.Context())
+			req.Header.Set("Authorization", token.header())
+			resp, err := next.Do(req)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if !bodyReplayable(req) {
+				return resp, nil
+			}
+
+			o.Invalidate(token)
+			fresh, err := o.Token(req.Context())
+			if err != nil {
+				// the 401 is more useful to the caller than the refresh failure
+				return resp, nil
+			}
+			drain(resp)
+			if req.GetBody != nil {
+				if retry.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+			retry.Header.Set("Authorization", fresh.header())
+			return next.Do(retry)
+		})
+	}
+}
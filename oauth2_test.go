@@ -0,0 +1,295 @@
+package simplehttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer issues numbered access tokens, recording each grant it sees.
+// With rejectRefresh set, it answers every refresh_token grant with
+// invalid_grant; while down is non-zero, it answers everything with 503.
+type tokenServer struct {
+	*httptest.Server
+	issued        int32
+	expiresIn     int
+	rejectRefresh bool
+	down          int32
+	mu            sync.Mutex
+	grants        []string
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+	s := &tokenServer{expiresIn: expiresIn}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&s.down) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		grant := r.PostFormValue("grant_type")
+		if grant == "refresh_token" {
+			grant += ":" + r.PostFormValue("refresh_token")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if s.rejectRefresh && strings.HasPrefix(grant, "refresh_token") {
+			s.mu.Lock()
+			s.grants = append(s.grants, grant)
+			s.mu.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		}
+		if id != "client" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		s.mu.Lock()
+		s.grants = append(s.grants, grant)
+		s.mu.Unlock()
+
+		n := atomic.AddInt32(&s.issued, 1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("token-%d", n),
+			"token_type":    "bearer",
+			"expires_in":    s.expiresIn,
+			"refresh_token": fmt.Sprintf("refresh-%d", n),
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// apiServer accepts only the access tokens allowed reports as valid.
+func apiServer(t *testing.T, allowed func(token string) bool) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !allowed(token) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(token))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestOAuth2(t *testing.T) { //nolint:funlen // subtests for each grant and failure mode
+	t.Parallel()
+
+	t.Run("ClientCredentials", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api := apiServer(t, func(token string) bool { return token == "token-1" })
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", HTTPClient: tokens.Client()})
+
+		c := New(api.URL)
+		c.Client = api.Client()
+		c.Use(o.Middleware())
+
+		for i := 0; i < 3; i++ {
+			response, err := c.Get("/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if response.Body != "token-1" {
+				t.Errorf("expected body %q, got %q", "token-1", response.Body)
+			}
+		}
+		if got := atomic.LoadInt32(&tokens.issued); got != 1 {
+			t.Errorf("expected the token to be cached, got %d fetches", got)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api := apiServer(t, func(token string) bool { return token != "" })
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", HTTPClient: tokens.Client()})
+
+		c := New(api.URL)
+		c.Client = api.Client()
+		c.Use(o.Middleware())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Get("/"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := atomic.LoadInt32(&tokens.issued); got != 1 {
+			t.Errorf("expected concurrent callers to share one fetch, got %d fetches", got)
+		}
+	})
+
+	t.Run("RefreshBeforeExpiry", func(t *testing.T) {
+		tokens := newTokenServer(t, 1)
+		o := NewOAuth2(OAuth2Config{
+			TokenURL:          tokens.URL,
+			ClientID:          "client",
+			ClientSecret:      "s3cret",
+			CredentialsInBody: true,
+			ExpiryDelta:       500 * time.Millisecond,
+			HTTPClient:        tokens.Client(),
+		})
+
+		first, err := o.Token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(600 * time.Millisecond)
+		second, err := o.Token(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if first.AccessToken == second.AccessToken {
+			t.Errorf("expected a new token near expiry, got %q twice", first.AccessToken)
+		}
+
+		tokens.mu.Lock()
+		defer tokens.mu.Unlock()
+		want := []string{"client_credentials", "refresh_token:refresh-1"}
+		if fmt.Sprint(tokens.grants) != fmt.Sprint(want) {
+			t.Errorf("expected grants %v, got %v", want, tokens.grants)
+		}
+	})
+
+	t.Run("RefreshTokenGrant", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", RefreshToken: "initial", HTTPClient: tokens.Client()})
+
+		if _, err := o.Token(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tokens.mu.Lock()
+		defer tokens.mu.Unlock()
+		if len(tokens.grants) != 1 || tokens.grants[0] != "refresh_token:initial" {
+			t.Errorf("expected a refresh_token grant, got %v", tokens.grants)
+		}
+	})
+
+	t.Run("RejectedRefreshToken", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		tokens.rejectRefresh = true
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", RefreshToken: "revoked", HTTPClient: tokens.Client()})
+
+		for i := 1; i <= 2; i++ {
+			token, err := o.Token(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := fmt.Sprintf("token-%d", i); token.AccessToken != want {
+				t.Errorf("expected %s, got %s", want, token.AccessToken)
+			}
+			o.Invalidate(token)
+		}
+		tokens.mu.Lock()
+		defer tokens.mu.Unlock()
+		want := []string{"refresh_token:revoked", "client_credentials", "refresh_token:refresh-1", "client_credentials"}
+		if fmt.Sprint(tokens.grants) != fmt.Sprint(want) {
+			t.Errorf("expected grants %v, got %v", want, tokens.grants)
+		}
+	})
+
+	t.Run("RejectedRefreshTokenOnly", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		tokens.rejectRefresh = true
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, RefreshToken: "revoked", HTTPClient: tokens.Client()})
+
+		_, err := o.Token(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "400: invalid_grant") {
+			t.Errorf("expected invalid_grant error, got %v", err)
+		}
+		tokens.mu.Lock()
+		defer tokens.mu.Unlock()
+		if len(tokens.grants) != 1 {
+			t.Errorf("expected no fallback grant, got %v", tokens.grants)
+		}
+	})
+
+	t.Run("RefreshTokenKeptWhileUnavailable", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", RefreshToken: "initial", HTTPClient: tokens.Client()})
+
+		atomic.StoreInt32(&tokens.down, 1)
+		if _, err := o.Token(context.Background()); err == nil {
+			t.Fatal("expected an error while the token endpoint is down")
+		}
+		atomic.StoreInt32(&tokens.down, 0)
+		if _, err := o.Token(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tokens.mu.Lock()
+		defer tokens.mu.Unlock()
+		if len(tokens.grants) != 1 || tokens.grants[0] != "refresh_token:initial" {
+			t.Errorf("expected the refresh token to be kept, got %v", tokens.grants)
+		}
+	})
+
+	t.Run("RetryOn401", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		api := apiServer(t, func(token string) bool { return token == "token-2" })
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "s3cret", HTTPClient: tokens.Client()})
+
+		c := New(api.URL)
+		c.Client = api.Client()
+		c.Use(o.Middleware())
+
+		response, err := c.R().Data("k", "v").Post("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK || response.Body != "token-2" {
+			t.Errorf("expected success with a fresh token, got %d %q", response.Code, response.Body)
+		}
+
+		// a token the server keeps rejecting is only retried once
+		api2 := apiServer(t, func(string) bool { return false })
+		c.BaseURL = api2.URL
+		response, err = c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, response.Code)
+		}
+		if got := atomic.LoadInt32(&tokens.issued); got != 3 {
+			t.Errorf("expected 3 fetches, got %d", got)
+		}
+	})
+
+	t.Run("BadCredentials", func(t *testing.T) {
+		tokens := newTokenServer(t, 3600)
+		o := NewOAuth2(OAuth2Config{TokenURL: tokens.URL, ClientID: "client", ClientSecret: "wrong", HTTPClient: tokens.Client()})
+
+		c := New(tokens.URL)
+		c.Client = tokens.Client()
+		c.Use(o.Middleware())
+
+		_, err := c.Get("/")
+		if err == nil {
+			t.Fatal("expected error for bad client credentials, got nil")
+		}
+		if !strings.Contains(err.Error(), "oauth2: token endpoint returned 401: invalid_client: bad credentials") {
+			t.Errorf("expected token endpoint error, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package simplehttp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// newHTTPProxy starts a forward proxy that requires user:pass, relaying plain
+// requests and tunnelling CONNECT. It counts the requests it handles.
+func newHTTPProxy(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var used int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&used, 1)
+		if r.Header.Get("Proxy-Authorization") != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		if r.Method == http.MethodConnect {
+			upstream, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				upstream.Close()
+				return
+			}
+			_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+			pipe(conn, upstream)
+			return
+		}
+
+		out := r.Clone(r.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Via", "test-proxy")
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &used
+}
+
+// newSOCKS5Proxy starts a SOCKS5 proxy (RFC 1928) that requires
+// username/password authentication (RFC 1929) and supports CONNECT.
+func newSOCKS5Proxy(t *testing.T) (string, *int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	var used int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&used, 1)
+			go serveSOCKS5(conn)
+		}
+	}()
+	return ln.Addr().String(), &used
+}
+
+func serveSOCKS5(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil
+		}
+		return b
+	}
+
+	// greeting: version, methods; choose username/password
+	hello := read(2)
+	if hello == nil || read(int(hello[1])) == nil {
+		conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{5, 2})
+	// auth: version, user, pass
+	ver := read(2)
+	if ver == nil {
+		conn.Close()
+		return
+	}
+	user := string(read(int(ver[1])))
+	plen := read(1)
+	if plen == nil {
+		conn.Close()
+		return
+	}
+	pass := string(read(int(plen[0])))
+	if user != "user" || pass != "pass" {
+		_, _ = conn.Write([]byte{1, 1})
+		conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{1, 0})
+
+	// request: version, CONNECT, reserved, address
+	req := read(4)
+	if req == nil || req[1] != 1 {
+		conn.Close()
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		n := read(1)
+		host = string(read(int(n[0])))
+	case 4:
+		host = net.IP(read(16)).String()
+	}
+	port := binary.BigEndian.Uint16(read(2))
+	upstream, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(&bufferedConn{Conn: conn, r: r}, upstream)
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// pipe copies between a and b until either side closes.
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() { _, _ = io.Copy(a, b); done <- struct{}{} }()
+	go func() { _, _ = io.Copy(b, a); done <- struct{}{} }()
+	<-done
+	a.Close()
+	b.Close()
+}
+
+func TestProxy(t *testing.T) { //nolint:funlen // subtests for each proxy scheme
+	t.Parallel()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("plain"))
+	}))
+	t.Cleanup(plain.Close)
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}))
+	t.Cleanup(secure.Close)
+
+	proxyURL := func(scheme, host string) string {
+		return (&url.URL{Scheme: scheme, User: url.UserPassword("user", "pass"), Host: host}).String()
+	}
+
+	t.Run("http proxy", func(t *testing.T) {
+		t.Parallel()
+		proxy, used := newHTTPProxy(t)
+		c := New(plain.URL)
+		if err := c.SetProxy(proxyURL("http", proxy.Listener.Addr().String())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "plain" || response.Headers["Via"] == nil {
+			t.Errorf("expected a proxied response, got %q %v", response.Body, response.Headers)
+		}
+		if atomic.LoadInt32(used) != 1 {
+			t.Errorf("expected the proxy to be used once, got %d", atomic.LoadInt32(used))
+		}
+	})
+
+	t.Run("https through CONNECT", func(t *testing.T) {
+		t.Parallel()
+		proxy, used := newHTTPProxy(t)
+		c := New(secure.URL)
+		c.Client = secure.Client()
+		if err := c.SetProxy(proxyURL("http", proxy.Listener.Addr().String())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "secure" || atomic.LoadInt32(used) != 1 {
+			t.Errorf("expected a tunnelled response, got %q after %d proxy requests", response.Body, atomic.LoadInt32(used))
+		}
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		t.Parallel()
+		proxy, _ := newHTTPProxy(t)
+		c := New(plain.URL)
+		_ = c.SetProxy("http://user:wrong@" + proxy.Listener.Addr().String())
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusProxyAuthRequired {
+			t.Errorf("expected status %d, got %d", http.StatusProxyAuthRequired, response.Code)
+		}
+	})
+
+	t.Run("socks5", func(t *testing.T) {
+		t.Parallel()
+		addr, used := newSOCKS5Proxy(t)
+		c := New(secure.URL)
+		c.Client = secure.Client()
+		if err := c.SetProxy(proxyURL("socks5", addr)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "secure" || atomic.LoadInt32(used) != 1 {
+			t.Errorf("expected a response through socks5, got %q after %d connections", response.Body, atomic.LoadInt32(used))
+		}
+	})
+
+	t.Run("no proxy", func(t *testing.T) {
+		t.Parallel()
+		proxy, used := newHTTPProxy(t)
+		c := New(plain.URL)
+		if err := c.SetProxy(proxyURL("http", proxy.Listener.Addr().String())); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := c.SetNoProxy("example.com", "127.0.0.0/8"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "plain" || atomic.LoadInt32(used) != 0 {
+			t.Errorf("expected a direct response, got %q after %d proxy requests", response.Body, atomic.LoadInt32(used))
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		c := New(plain.URL)
+		for _, u := range []string{"ftp://proxy:21", "http://", "://bad"} {
+			if err := c.SetProxy(u); err == nil {
+				t.Errorf("expected an error for %q", u)
+			}
+		}
+		c.Client.Transport = otherTransport{}
+		if err := c.SetProxy("http://proxy:3128"); err == nil {
+			t.Error("expected an error for a non-*http.Transport transport")
+		}
+	})
+}
+
+// otherTransport is a RoundTripper that isn't an *http.Transport.
+type otherTransport struct{}
+
+func (otherTransport) RoundTrip(*http.Request) (*http.Response, error) { return nil, io.EOF }
+
+// TestProxyReplacedDefaultTransport isn't parallel, since it swaps out
+// http.DefaultTransport for the whole process.
+func TestProxyReplacedDefaultTransport(t *testing.T) {
+	saved := http.DefaultTransport
+	http.DefaultTransport = otherTransport{}
+	defer func() { http.DefaultTransport = saved }()
+
+	if err := New("").SetProxy("http://proxy:3128"); err == nil {
+		t.Error("expected an error for a non-*http.Transport default transport")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	t.Parallel()
+
+	noProxy := []string{"example.com", ".internal.net", "10.0.0.0/8", "192.168.1.1", "api.test:8443"}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/", true},
+		{"https://www.example.com/", true},
+		{"https://notexample.com/", false},
+		{"http://a.internal.net/", true},
+		{"http://internal.net/", false},
+		{"http://10.1.2.3/", true},
+		{"http://11.1.2.3/", false},
+		{"http://192.168.1.1:8080/", true},
+		{"https://api.test:8443/", true},
+		{"https://api.test/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := bypassProxy(u, noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%s) = %v, expected %v", tt.url, got, tt.want)
+		}
+	}
+	u, _ := url.Parse("https://anything/")
+	if !bypassProxy(u, []string{"*"}) {
+		t.Error("expected * to match every host")
+	}
+}
@@ -0,0 +1,152 @@
+package simplehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// epochThreshold separates X-RateLimit-Reset values sent as Unix timestamps
+// (GitHub style) from those sent as delta seconds.
+const epochThreshold = 1_000_000_000
+
+// RateLimit is the quota a server advertised in its RateLimit-* or
+// X-RateLimit-* response headers.
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// parseRateLimit reads the IETF draft RateLimit-Limit/-Remaining/-Reset
+// fields, the combined RateLimit field, or their X-RateLimit-* equivalents.
+// It returns nil unless at least the remaining quota is present.
+func parseRateLimit(h http.Header, now time.Time) *RateLimit {
+	limit, remaining, reset := h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset")
+	if combined := h.Get("RateLimit"); combined != "" && remaining == "" {
+		limit, remaining, reset = parseRateLimitField(combined)
+	}
+	epochReset := false
+	if remaining == "" {
+		limit, remaining, reset = h.Get("X-RateLimit-Limit"), h.Get("X-RateLimit-Remaining"), h.Get("X-RateLimit-Reset")
+		epochReset = true
+	}
+
+	rl := &RateLimit{}
+	var err error
+	if rl.Remaining, err = strconv.Atoi(strings.TrimSpace(remaining)); err != nil {
+		return nil
+	}
+	rl.Limit, _ = strconv.Atoi(strings.TrimSpace(limit))
+	if secs, err := strconv.ParseInt(strings.TrimSpace(reset), 10, 64); err == nil && secs >= 0 {
+		if epochReset && secs >= epochThreshold {
+			rl.Reset = time.Unix(secs, 0)
+		} else {
+			rl.Reset = now.Add(time.Duration(secs) * time.Second)
+		}
+	}
+	return rl
+}
+
+// parseRateLimitField splits the combined RateLimit field, accepting both
+// "limit=100, remaining=50, reset=30" and `"default";r=50;t=30`.
+func parseRateLimitField(v string) (limit, remaining, reset string) {
+	for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "limit", "l":
+			limit = value
+		case "remaining", "r":
+			remaining = value
+		case "reset", "t":
+			reset = value
+		}
+	}
+	return limit, remaining, reset
+}
+
+// quotaPacer spreads requests to each host evenly over the quota the host
+// advertised, so it's used up no sooner than it resets.
+type quotaPacer struct {
+	mu    sync.Mutex
+	hosts map[string]*hostQuota
+}
+
+type hostQuota struct {
+	next     time.Time
+	interval time.Duration
+	reset    time.Time
+}
+
+func (p *quotaPacer) host(host string) *hostQuota {
+	q, ok := p.hosts[host]
+	if !ok {
+		q = &hostQuota{}
+		p.hosts[host] = q
+	}
+	return q
+}
+
+// update records the quota host reported in its latest response.
+func (p *quotaPacer) update(host string, rl *RateLimit, now time.Time) {
+	if rl == nil || rl.Reset.IsZero() {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q := p.host(host)
+	q.reset = rl.Reset
+	if rl.Remaining <= 0 {
+		q.interval = 0
+		if rl.Reset.After(q.next) {
+			q.next = rl.Reset
+		}
+		return
+	}
+	q.interval = rl.Reset.Sub(now) / time.Duration(rl.Remaining)
+}
+
+// wait blocks until host's quota allows another request, or ctx is done.
+func (p *quotaPacer) wait(ctx context.Context, host string) error {
+	p.mu.Lock()
+	q := p.host(host)
+	now := time.Now()
+	at := q.next
+	if at.Before(now) {
+		at = now
+	}
+	if at.Before(q.reset) {
+		q.next = at.Add(q.interval)
+	} else {
+		q.next = at
+	}
+	p.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		if err := sleep(ctx, d); err != nil {
+			return fmt.Errorf("waiting for %s quota: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// SetAdaptiveRateLimit paces requests to each host using the quota it
+// advertises in RateLimit-* or X-RateLimit-* headers: once a host reports
+// its remaining quota, later requests are spread evenly until the reset, and
+// held until the reset when none is left.
+func (client *HTTPClient) SetAdaptiveRateLimit(enabled bool) {
+	var p *quotaPacer
+	if enabled {
+		p = &quotaPacer{hosts: make(map[string]*hostQuota)}
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.pacer = p
+}
@@ -0,0 +1,132 @@
+package simplehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1_700_000_000, 0)
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    *RateLimit
+	}{
+		{"None", map[string]string{}, nil},
+		{
+			"IETF",
+			map[string]string{"RateLimit-Limit": "100", "RateLimit-Remaining": "42", "RateLimit-Reset": "30"},
+			&RateLimit{Limit: 100, Remaining: 42, Reset: now.Add(30 * time.Second)},
+		},
+		{
+			"Combined",
+			map[string]string{"RateLimit": "limit=10, remaining=3, reset=5"},
+			&RateLimit{Limit: 10, Remaining: 3, Reset: now.Add(5 * time.Second)},
+		},
+		{
+			"CombinedStructured",
+			map[string]string{"RateLimit": `"default";r=7;t=60`},
+			&RateLimit{Remaining: 7, Reset: now.Add(time.Minute)},
+		},
+		{
+			"GitHub",
+			map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999", "X-RateLimit-Reset": "1700000600"},
+			&RateLimit{Limit: 5000, Remaining: 4999, Reset: time.Unix(1_700_000_600, 0)},
+		},
+		{
+			"XDeltaSeconds",
+			map[string]string{"X-RateLimit-Remaining": "1", "X-RateLimit-Reset": "10"},
+			&RateLimit{Remaining: 1, Reset: now.Add(10 * time.Second)},
+		},
+		{"Malformed", map[string]string{"RateLimit-Remaining": "lots"}, nil},
+	}
+
+	for _, tc := range cases {
+		h := http.Header{}
+		for k, v := range tc.headers {
+			h.Set(k, v)
+		}
+		got := parseRateLimit(h, now)
+		switch {
+		case tc.want == nil && got != nil:
+			t.Errorf("%s: expected nil, got %+v", tc.name, got)
+		case tc.want != nil && (got == nil || *got != *tc.want):
+			t.Errorf("%s: expected %+v, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestAdaptiveRateLimit(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("RateLimit-Limit", "2")
+		w.Header().Set("RateLimit-Remaining", r.URL.Query().Get("remaining"))
+		w.Header().Set("RateLimit-Reset", "1")
+	}))
+	defer ts.Close()
+
+	t.Run("Exposed", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().Query("remaining", "1").Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.RateLimit == nil || response.RateLimit.Limit != 2 || response.RateLimit.Remaining != 1 {
+			t.Errorf("unexpected rate limit %+v", response.RateLimit)
+		}
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetAdaptiveRateLimit(true)
+
+		if _, err := c.R().Query("remaining", "0").Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		start := time.Now()
+		if _, err := c.R().Query("remaining", "1000").Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+			t.Errorf("expected to wait for the quota reset, took %v", elapsed)
+		}
+	})
+
+	t.Run("Plenty", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetAdaptiveRateLimit(true)
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if _, err := c.R().Query("remaining", "1000").Get("/"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected little pacing with plenty of quota, took %v", elapsed)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if _, err := c.R().Query("remaining", "0").Get("/"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected no pacing when disabled, took %v", elapsed)
+		}
+	})
+}
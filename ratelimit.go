@@ -0,0 +1,150 @@
+package simplehttp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenBucket holds up to burst tokens, refilled at rate tokens per second.
+// Each request takes one token, waiting for it if the bucket is empty.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait before the token is really theirs.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that was never used.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens++
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	d := b.reserve()
+	if d == 0 {
+		return nil
+	}
+	if err := sleep(ctx, d); err != nil {
+		b.cancel()
+		return err
+	}
+	return nil
+}
+
+// hostBuckets lazily creates one token bucket per host, all sharing the same
+// rate and burst.
+type hostBuckets struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (h *hostBuckets) bucket(host string) *tokenBucket {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.buckets[host]
+	if !ok {
+		b = newTokenBucket(h.rate, h.burst)
+		h.buckets[host] = b
+	}
+	return b
+}
+
+// SetRateLimit caps the client at rate requests per second across all hosts,
+// allowing bursts of up to burst requests. A rate of zero or less removes the
+// limit.
+func (client *HTTPClient) SetRateLimit(rate float64, burst int) {
+	var b *tokenBucket
+	if rate > 0 {
+		b = newTokenBucket(rate, burst)
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.limiter = b
+}
+
+// SetHostRateLimit caps the client at rate requests per second to each host
+// separately, allowing bursts of up to burst requests per host. A rate of zero
+// or less removes the limit.
+func (client *HTTPClient) SetHostRateLimit(rate float64, burst int) {
+	var h *hostBuckets
+	if rate > 0 {
+		h = &hostBuckets{rate: rate, burst: burst, buckets: make(map[string]*tokenBucket)}
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.hostLimiter = h
+}
+
+// waitForLimits blocks until the client-wide and per-host limiters and the
+// host's advertised quota all allow a request to host, or ctx is done. Tokens
+// already taken are returned if a later wait fails, since no request is sent.
+func (client snapshot) waitForLimits(ctx context.Context, host string) error {
+	var taken []*tokenBucket
+	release := func() {
+		for _, b := range taken {
+			b.cancel()
+		}
+	}
+
+	if client.limiter != nil {
+		if err := client.limiter.wait(ctx); err != nil {
+			return fmt.Errorf("waiting for rate limit: %w", err)
+		}
+		taken = append(taken, client.limiter)
+	}
+	if client.hostLimiter != nil {
+		b := client.hostLimiter.bucket(host)
+		if err := b.wait(ctx); err != nil {
+			release()
+			return fmt.Errorf("waiting for rate limit on %s: %w", host, err)
+		}
+		taken = append(taken, b)
+	}
+	if client.pacer != nil {
+		if err := client.pacer.wait(ctx, host); err != nil {
+			release()
+			return err
+		}
+	}
+	return nil
+}
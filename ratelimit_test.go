@@ -0,0 +1,135 @@
+package simplehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) { //nolint:funlen // subtests for each limiter scope
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	other := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer other.Close()
+
+	t.Run("Global", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRateLimit(20, 1)
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if _, err := c.Get("/icanhazdadjoke"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		// one token up front, then one every 50ms
+		if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+			t.Errorf("expected requests to be paced over at least 200ms, took %v", elapsed)
+		}
+	})
+
+	t.Run("Burst", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRateLimit(1, 5)
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if _, err := c.Get("/icanhazdadjoke"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+			t.Errorf("expected a burst of 5 to go through without waiting, took %v", elapsed)
+		}
+	})
+
+	t.Run("PerHost", func(t *testing.T) {
+		c := New("")
+		c.Client = ts.Client()
+		c.SetHostRateLimit(1, 1)
+
+		start := time.Now()
+		for _, u := range []string{ts.URL, other.URL} {
+			if _, err := c.Get(u + "/icanhazdadjoke"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+			t.Errorf("expected separate buckets per host, took %v", elapsed)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := c.GetContext(ctx, ts.URL+"/icanhazdadjoke")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the second request to the same host to wait, got: %v", err)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRateLimit(0.1, 1)
+
+		if _, err := c.Get("/icanhazdadjoke"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := c.GetContext(ctx, "/icanhazdadjoke")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the wait to stop at the deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("CanceledHostWait", func(t *testing.T) {
+		c := New("")
+		c.Client = ts.Client()
+		c.SetRateLimit(0.01, 2)
+		c.SetHostRateLimit(0.01, 1)
+
+		if _, err := c.Get(ts.URL + "/icanhazdadjoke"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := c.GetContext(ctx, ts.URL+"/icanhazdadjoke"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+
+		// the client-wide token taken by the canceled request was returned
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := c.GetContext(ctx, other.URL+"/icanhazdadjoke"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRateLimit(0.1, 1)
+		c.SetRateLimit(0, 0)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if _, err := c.Get("/icanhazdadjoke"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+			t.Errorf("expected no limit, took %v", elapsed)
+		}
+	})
+}
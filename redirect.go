@@ -0,0 +1,175 @@
+package simplehttp
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxRedirects matches the limit net/http applies when a client has no
+// CheckRedirect of its own.
+const defaultMaxRedirects = 10
+
+// defaultSensitiveHeaders are removed from redirects that leave the original
+// host or downgrade from https, unless SetSensitiveHeaders says otherwise.
+var defaultSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Auth-Token"}
+
+// RedirectPolicy controls which redirects are followed. The zero value
+// follows up to 10 redirects, as net/http does.
+type RedirectPolicy struct {
+	// MaxRedirects is the most redirects followed in one call before it
+	// fails; zero means 10.
+	MaxRedirects int
+	// NoFollow returns redirect responses to the caller instead of following
+	// them.
+	NoFollow bool
+	// SameHostOnly follows redirects only within the host of the call's
+	// first request; a redirect elsewhere is returned to the caller.
+	SameHostOnly bool
+	// PreserveMethod resends the original method and body on 301 and 302
+	// redirects, which net/http otherwise turns into GETs. 307 and 308
+	// redirects always preserve them, and 303 always switches to GET.
+	PreserveMethod bool
+}
+
+// Redirect is one redirect followed on the way to the final response.
+type Redirect struct {
+	// URL is the URL that answered with the redirect.
+	URL  string
+	Code int
+}
+
+// SetRedirectPolicy replaces the client's redirect policy. A CheckRedirect
+// set on client.Client still runs after the policy allows a redirect.
+func (client *HTTPClient) SetRedirectPolicy(policy RedirectPolicy) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.redirect = policy
+}
+
+// SetSensitiveHeaders replaces the headers removed from a redirect that leaves
+// the host of the call's first request, including for another port, or that
+// goes from https to http. Calling it with no names keeps every header.
+func (client *HTTPClient) SetSensitiveHeaders(names ...string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.sensitiveHeaders = append([]string{}, names...)
+}
+
+// httpClient returns a copy of the *http.Client for one call, with a redirect
+// check that applies the client's policies before any CheckRedirect the
+// caller installed.
+func (client snapshot) httpClient(r *Request) *http.Client {
+	hc := *client.client
+	next := hc.CheckRedirect
+	policy := client.redirect
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		scopeAuthOnRedirect(req, via, r, client)
+		stripSensitiveHeaders(req, via, r, client)
+		if err := policy.check(req, via, next); err != nil {
+			return err
+		}
+		if policy.PreserveMethod {
+			return preserveMethod(req, via[len(via)-1])
+		}
+		return nil
+	}
+	return &hc
+}
+
+// check decides whether the redirect to req is followed, deferring to next,
+// the caller's own CheckRedirect, when the policy allows it.
+func (policy RedirectPolicy) check(req *http.Request, via []*http.Request, next func(*http.Request, []*http.Request) error) error {
+	if policy.NoFollow {
+		return http.ErrUseLastResponse
+	}
+	if policy.SameHostOnly && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		return http.ErrUseLastResponse
+	}
+	if policy.MaxRedirects > 0 && len(via) > policy.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", policy.MaxRedirects)
+	}
+	if next != nil {
+		return next(req, via)
+	}
+	if policy.MaxRedirects <= 0 && len(via) >= defaultMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", defaultMaxRedirects)
+	}
+	return nil
+}
+
+// stripSensitiveHeaders removes the sensitive headers from a redirect to
+// another host or from https to http. net/http copies every header of the
+// first request onto each redirect, dropping only Authorization, Cookie and
+// WWW-Authenticate, and only for other domains. The client's own credentials
+// are left to net/http under AuthAnyHost, which asks for them everywhere, so
+// they still reach other ports and subdomains of the first host.
+func stripSensitiveHeaders(req *http.Request, via []*http.Request, r *Request, client snapshot) {
+	first := via[0].URL
+	downgrade := strings.EqualFold(first.Scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https")
+	if strings.EqualFold(first.Host, req.URL.Host) && !downgrade {
+		return
+	}
+	names := client.sensitiveHeaders
+	if names == nil {
+		names = defaultSensitiveHeaders
+	}
+	keepAuth := client.authScope == AuthAnyHost && client.auth.set() && !r.auth.set() && !downgrade
+	for _, name := range names {
+		if keepAuth && strings.EqualFold(name, "Authorization") {
+			continue
+		}
+		req.Header.Del(name)
+	}
+}
+
+// preserveMethod turns the GET net/http made for a 301 or 302 back into the
+// previous request's method, with its body replayed. A body that can't be
+// replayed ends the redirects, returning the 301 or 302 to the caller.
+func preserveMethod(req, prev *http.Request) error {
+	if req.Response == nil || req.Method == prev.Method {
+		return nil
+	}
+	if code := req.Response.StatusCode; code != http.StatusMovedPermanently && code != http.StatusFound {
+		return nil
+	}
+	if !bodyReplayable(prev) {
+		return http.ErrUseLastResponse
+	}
+	req.Method = prev.Method
+	if prev.GetBody != nil {
+		body, err := prev.GetBody()
+		if err != nil {
+			return err
+		}
+		req.Body, req.GetBody, req.ContentLength = body, prev.GetBody, prev.ContentLength
+	}
+	if ct := prev.Header.Get("Content-Type"); ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	return nil
+}
+
+// redirectHistory lists the redirects that led to resp, oldest first, by
+// walking back through the requests net/http created for each one.
+func redirectHistory(resp *http.Response) []Redirect {
+	var hops []Redirect
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		if req.Response.Request == nil {
+			break
+		}
+		hops = append(hops, Redirect{URL: req.Response.Request.URL.String(), Code: req.Response.StatusCode})
+	}
+	for i, j := 0, len(hops)-1; i < j; i, j = i+1, j-1 {
+		hops[i], hops[j] = hops[j], hops[i]
+	}
+	return hops
+}
+
+// finalURL is the URL that produced resp, after any redirects.
+func finalURL(resp *http.Response) string {
+	if resp.Request == nil {
+		return ""
+	}
+	return resp.Request.URL.String()
+}
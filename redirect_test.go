@@ -0,0 +1,307 @@
+package simplehttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRedirectServer redirects /hop/N through /hop/N-1 down to /hop/0, which
+// echoes the method and body; /away?to=URL redirects elsewhere and /found,
+// /see-other and /temporary answer with those statuses pointing at /hop/0;
+// /headers echoes the request headers as JSON.
+func newRedirectServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/hop/0":
+			_, _ = fmt.Fprintf(w, "%s %s %s", r.Method, body, r.Header.Get("Content-Type"))
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusMovedPermanently)
+		case r.URL.Path == "/headers":
+			_ = json.NewEncoder(w).Encode(r.Header)
+		case r.URL.Path == "/away":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+		case r.URL.Path == "/found":
+			http.Redirect(w, r, "/hop/0", http.StatusFound)
+		case r.URL.Path == "/see-other":
+			http.Redirect(w, r, "/hop/0", http.StatusSeeOther)
+		case r.URL.Path == "/temporary":
+			http.Redirect(w, r, "/hop/0", http.StatusTemporaryRedirect)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRedirectPolicy(t *testing.T) { //nolint:funlen // subtests for each policy option
+	t.Parallel()
+	ts := newRedirectServer(t)
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("other"))
+	}))
+	t.Cleanup(other.Close)
+
+	newClient := func(policy RedirectPolicy) *HTTPClient {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRedirectPolicy(policy)
+		return c
+	}
+
+	t.Run("history", func(t *testing.T) {
+		t.Parallel()
+		response, err := newClient(RedirectPolicy{}).Get("/hop/2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.URL != ts.URL+"/hop/0" {
+			t.Errorf("expected final URL %s/hop/0, got %s", ts.URL, response.URL)
+		}
+		want := []Redirect{{ts.URL + "/hop/2", http.StatusMovedPermanently}, {ts.URL + "/hop/1", http.StatusMovedPermanently}}
+		if fmt.Sprint(response.Redirects) != fmt.Sprint(want) {
+			t.Errorf("expected redirects %v, got %v", want, response.Redirects)
+		}
+	})
+
+	t.Run("no redirects", func(t *testing.T) {
+		t.Parallel()
+		response, err := newClient(RedirectPolicy{}).Get("/hop/0")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.URL != ts.URL+"/hop/0" || len(response.Redirects) != 0 {
+			t.Errorf("expected no redirects, got %s %v", response.URL, response.Redirects)
+		}
+	})
+
+	t.Run("max redirects", func(t *testing.T) {
+		t.Parallel()
+		c := newClient(RedirectPolicy{MaxRedirects: 2})
+		if _, err := c.Get("/hop/2"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		_, err := c.Get("/hop/3")
+		if err == nil || !strings.Contains(err.Error(), "stopped after 2 redirects") {
+			t.Errorf("expected redirect limit error, got %v", err)
+		}
+	})
+
+	t.Run("no follow", func(t *testing.T) {
+		t.Parallel()
+		response, err := newClient(RedirectPolicy{NoFollow: true}).Get("/hop/1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusMovedPermanently {
+			t.Errorf("expected status %d, got %d", http.StatusMovedPermanently, response.Code)
+		}
+		if loc := response.Headers["Location"]; len(loc) != 1 || loc[0] != "/hop/0" {
+			t.Errorf("expected Location /hop/0, got %v", loc)
+		}
+	})
+
+	t.Run("same host only", func(t *testing.T) {
+		t.Parallel()
+		c := newClient(RedirectPolicy{SameHostOnly: true})
+		response, err := c.R().Query("to", other.URL).Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusFound {
+			t.Errorf("expected status %d, got %d", http.StatusFound, response.Code)
+		}
+		response, err = c.Get("/hop/1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Errorf("expected same-host redirect to be followed, got %d", response.Code)
+		}
+	})
+
+	t.Run("method handling", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			path     string
+			preserve bool
+			want     string
+		}{
+			{"/found", false, "GET  "},
+			{"/found", true, `POST {"a":1} application/json`},
+			{"/see-other", true, "GET  "},
+			{"/temporary", false, `POST {"a":1} application/json`},
+		}
+		for _, tt := range tests {
+			c := newClient(RedirectPolicy{PreserveMethod: tt.preserve})
+			response, err := c.R().JSON(map[string]int{"a": 1}).Post(tt.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if response.Body != tt.want {
+				t.Errorf("%s with PreserveMethod=%v: expected %q, got %q", tt.path, tt.preserve, tt.want, response.Body)
+			}
+		}
+	})
+
+	t.Run("unreplayable body is not redirected", func(t *testing.T) {
+		t.Parallel()
+		c := newClient(RedirectPolicy{PreserveMethod: true})
+		response, err := c.R().BodyReader(io.NopCloser(strings.NewReader("x")), -1).Post("/found")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusFound {
+			t.Errorf("expected status %d, got %d", http.StatusFound, response.Code)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+		resp, err := newClient(RedirectPolicy{}).R().Stream(http.MethodGet, "/hop/1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.URL != ts.URL+"/hop/0" || len(resp.Redirects) != 1 {
+			t.Errorf("expected one redirect to /hop/0, got %s %v", resp.URL, resp.Redirects)
+		}
+	})
+}
+
+func TestSensitiveHeadersOnRedirect(t *testing.T) { //nolint:funlen // subtests for each header list
+	t.Parallel()
+	ts := newRedirectServer(t)
+	other := newRedirectServer(t)
+
+	headersSeen := func(t *testing.T, response HTTPResponse) http.Header {
+		t.Helper()
+		var h http.Header
+		if err := response.JSON(&h); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return h
+	}
+	newClient := func() *HTTPClient {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Headers["X-Api-Key"] = "key"
+		c.Headers["X-Trace"] = "trace"
+		return c
+	}
+
+	t.Run("stripped when leaving the host", func(t *testing.T) {
+		t.Parallel()
+		c := newClient()
+		response, err := c.R().Header("Authorization", "Token t").Query("to", other.URL+"/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		h := headersSeen(t, response)
+		if h.Get("X-Api-Key") != "" || h.Get("Authorization") != "" {
+			t.Errorf("expected sensitive headers to be stripped, got %v", h)
+		}
+		if h.Get("X-Trace") != "trace" {
+			t.Errorf("expected X-Trace to survive, got %v", h)
+		}
+	})
+
+	t.Run("kept on the same host", func(t *testing.T) {
+		t.Parallel()
+		c := newClient()
+		response, err := c.R().Query("to", "/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if h := headersSeen(t, response); h.Get("X-Api-Key") != "key" {
+			t.Errorf("expected X-Api-Key on a same-host redirect, got %v", h)
+		}
+	})
+
+	t.Run("custom list", func(t *testing.T) {
+		t.Parallel()
+		c := newClient()
+		c.SetSensitiveHeaders("X-Trace")
+		response, err := c.R().Query("to", other.URL+"/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		h := headersSeen(t, response)
+		if h.Get("X-Api-Key") != "key" || h.Get("X-Trace") != "" {
+			t.Errorf("expected only X-Trace to be stripped, got %v", h)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		c := newClient()
+		c.SetSensitiveHeaders()
+		response, err := c.R().Query("to", other.URL+"/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if h := headersSeen(t, response); h.Get("X-Api-Key") != "key" {
+			t.Errorf("expected X-Api-Key to be kept, got %v", h)
+		}
+	})
+
+	t.Run("AuthAnyHost credentials", func(t *testing.T) {
+		t.Parallel()
+		// serve example.com and its subdomains, which the test certificate
+		// covers, from the test servers' ports on 127.0.0.1
+		tr := ts.Client().Transport.(*http.Transport).Clone()
+		tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			_, port, _ := net.SplitHostPort(addr)
+			return (&net.Dialer{}).DialContext(ctx, network, net.JoinHostPort("127.0.0.1", port))
+		}
+		port := func(s *httptest.Server) string {
+			_, p, _ := net.SplitHostPort(s.Listener.Addr().String())
+			return p
+		}
+		c := New("https://example.com:" + port(ts))
+		c.Client = &http.Client{Transport: tr}
+		c.Headers["X-Api-Key"] = "key"
+		c.SetBearerToken("secret")
+		c.SetAuthScope(AuthAnyHost)
+
+		// kept for a subdomain on another port, where net/http would send it
+		response, err := c.R().Query("to", "https://api.example.com:"+port(other)+"/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		h := headersSeen(t, response)
+		if h.Get("Authorization") != "Bearer secret" || h.Get("X-Api-Key") != "" {
+			t.Errorf("expected only Authorization to be kept, got %v", h)
+		}
+
+		// net/http itself drops it for another domain
+		response, err = c.R().Query("to", other.URL+"/headers").Get("/away")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if h := headersSeen(t, response); h.Get("Authorization") != "" {
+			t.Errorf("expected Authorization to be dropped for another domain, got %v", h)
+		}
+	})
+
+	t.Run("stripped on https downgrade", func(t *testing.T) {
+		t.Parallel()
+		first, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/next", nil)
+		req.Header.Set("Cookie", "session=abc")
+		req.Header.Set("X-Trace", "trace")
+		stripSensitiveHeaders(req, []*http.Request{first}, New("").R(), New("").snapshot())
+		if req.Header.Get("Cookie") != "" || req.Header.Get("X-Trace") != "trace" {
+			t.Errorf("expected only Cookie to be stripped, got %v", req.Header)
+		}
+	})
+}
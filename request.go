@@ -0,0 +1,134 @@
+package simplehttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Request holds per-call values that are layered over the client defaults
+// when it is sent. Create one with HTTPClient.R; the client is never mutated.
+type Request struct {
+	client  *HTTPClient
+	ctx     context.Context
+	headers map[string]string
+	params  map[string]string
+	data    map[string]string
+	json    any
+	hasJSON bool
+	form    url.Values
+	files   []MultipartFile
+
+	reader     io.Reader
+	readerSize int64
+	hasReader  bool
+
+	encoding    BodyEncoding
+	hasEncoding bool
+
+	auth credentials
+}
+
+func (client *HTTPClient) R() *Request {
+	return &Request{
+		client:  client,
+		ctx:     context.Background(),
+		headers: make(map[string]string),
+		params:  make(map[string]string),
+		data:    make(map[string]string),
+	}
+}
+
+func (r *Request) WithContext(ctx context.Context) *Request {
+	r.ctx = ctx
+	return r
+}
+
+// Header sets a header for this request, overriding any client default.
+func (r *Request) Header(key, value string) *Request {
+	r.headers[key] = value
+	return r
+}
+
+// Query sets a query parameter for this request, overriding any client default.
+func (r *Request) Query(key, value string) *Request {
+	r.params[key] = value
+	return r
+}
+
+// Data sets a request body field for this request, overriding any client default.
+func (r *Request) Data(key, value string) *Request {
+	r.data[key] = value
+	return r
+}
+
+// JSON sets the request body to v marshaled with encoding/json, replacing any
+// Data fields. Content-Type defaults to application/json.
+func (r *Request) JSON(v any) *Request {
+	r.json = v
+	r.hasJSON = true
+	r.hasReader = false
+	return r
+}
+
+// BodyReader streams rd as the request body without buffering it, replacing
+// any JSON or Data body. size is the length in bytes, or -1 if unknown. If rd
+// is an io.Seeker the body can be replayed on redirects and retries. rd is
+// never closed; the caller owns it. No Content-Type is set unless given with
+// Header.
+func (r *Request) BodyReader(rd io.Reader, size int64) *Request {
+	r.reader = rd
+	r.readerSize = size
+	r.hasReader = true
+	r.hasJSON = false
+	return r
+}
+
+// Form switches this request to a form-urlencoded body built from the Data
+// fields plus values, which may hold several entries per key and override
+// Data fields of the same name. It replaces any JSON body; once files are
+// attached the values are sent as multipart fields instead.
+func (r *Request) Form(values url.Values) *Request {
+	if r.form == nil {
+		r.form = make(url.Values, len(values))
+	}
+	for k, v := range values {
+		r.form[k] = append([]string(nil), v...)
+	}
+	r.hasJSON = false
+	r.hasReader = false
+	if len(r.files) == 0 {
+		r.encoding = EncodeForm
+	}
+	r.hasEncoding = true
+	return r
+}
+
+func (r *Request) Send(method, path string) (HTTPResponse, error) {
+	return sendRequest(r, path, method)
+}
+
+func (r *Request) Get(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodGet)
+}
+
+func (r *Request) Post(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodPost)
+}
+
+func (r *Request) Patch(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodPatch)
+}
+
+func (r *Request) Put(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodPut)
+}
+
+func (r *Request) Delete(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodDelete)
+}
+
+func (r *Request) Head(path string) (HTTPResponse, error) {
+	return sendRequest(r, path, http.MethodHead)
+}
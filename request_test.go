@@ -0,0 +1,305 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequestBuilder(t *testing.T) { //nolint:funlen // subtests for each builder setter
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+
+	t.Run("Header", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Headers["X-Default"] = "client"
+		c.Headers["X-Override"] = "client"
+
+		response, err := c.R().Header("x-override", "request").Get("/header")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got http.Header
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Get("X-Default") != "client" {
+			t.Errorf("expected X-Default %q, got %q", "client", got.Get("X-Default"))
+		}
+		if got.Get("X-Override") != "request" {
+			t.Errorf("expected X-Override %q, got %q", "request", got.Get("X-Override"))
+		}
+		if c.Headers["X-Override"] != "client" {
+			t.Errorf("expected client default to be untouched, got %q", c.Headers["X-Override"])
+		}
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Params["foo"] = "bar"
+
+		response, err := c.R().Query("foo", "baz").Get("/query-parameter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "foo=baz" {
+			t.Errorf("expected body %q, got %q", "foo=baz", response.Body)
+		}
+
+		response, err = c.Get("/query-parameter")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "foo=bar" {
+			t.Errorf("expected body %q, got %q", "foo=bar", response.Body)
+		}
+	})
+
+	t.Run("Data", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Data["a"] = "1"
+
+		response, err := c.R().Data("b", "2").Post("/echo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got Echo
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Body != `{"a":"1","b":"2"}` {
+			t.Errorf("expected body %q, got %q", `{"a":"1","b":"2"}`, got.Body)
+		}
+		if len(c.Data) != 1 {
+			t.Errorf("expected client data to be untouched, got %v", c.Data)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Data["ignored"] = "yes"
+
+		type item struct {
+			Name  string         `json:"name"`
+			Count int            `json:"count"`
+			Ok    bool           `json:"ok"`
+			Tags  []string       `json:"tags"`
+			Meta  map[string]any `json:"meta"`
+		}
+		body := item{Name: "widget", Count: 3, Ok: true, Tags: []string{"a", "b"}, Meta: map[string]any{"depth": 1.5}}
+
+		response, err := c.R().JSON(body).Post("/echo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got Echo
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := `{"name":"widget","count":3,"ok":true,"tags":["a","b"],"meta":{"depth":1.5}}`
+		if got.Body != want {
+			t.Errorf("expected body %q, got %q", want, got.Body)
+		}
+		if got.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected Content-Type %q, got %q", "application/json", got.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("JSONContentTypeOverride", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().Header("Content-Type", "application/vnd.api+json").JSON([]int{1, 2}).Post("/content-type")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "application/vnd.api+json" {
+			t.Errorf("expected body %q, got %q", "application/vnd.api+json", response.Body)
+		}
+	})
+
+	t.Run("JSONMarshalError", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		_, err := c.R().JSON(make(chan int)).Post("/echo")
+		if err == nil {
+			t.Fatal("expected error for unmarshalable body, got nil")
+		}
+		if !strings.Contains(err.Error(), "simplehttp: POST /echo: marshaling request data") {
+			t.Errorf("expected marshaling error, got: %v", err)
+		}
+	})
+
+	t.Run("Send", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().Send(http.MethodPost, "/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "TestPost: text response" {
+			t.Errorf("expected body %q, got %q", "TestPost: text response", response.Body)
+		}
+	})
+}
+
+func TestFormBody(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+
+	t.Run("ClientEncoding", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetBodyEncoding(EncodeForm)
+		c.Data["grant_type"] = "client_credentials"
+
+		response, err := c.R().Data("scope", "read write").Post("/echo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got Echo
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Body != "grant_type=client_credentials&scope=read+write" {
+			t.Errorf("expected body %q, got %q", "grant_type=client_credentials&scope=read+write", got.Body)
+		}
+		if got.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Errorf("expected Content-Type %q, got %q", "application/x-www-form-urlencoded", got.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("OverridesClientContentType", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Headers["Content-Type"] = "application/json"
+
+		response, err := c.R().Form(url.Values{"a": {"1"}}).Post("/content-type")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "application/x-www-form-urlencoded" {
+			t.Errorf("expected body %q, got %q", "application/x-www-form-urlencoded", response.Body)
+		}
+
+		// a client-default JSON media type still applies to JSON bodies
+		c.Headers["Content-Type"] = "application/vnd.api+json"
+		response, err = c.R().JSON([]int{1}).Post("/content-type")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "application/vnd.api+json" {
+			t.Errorf("expected body %q, got %q", "application/vnd.api+json", response.Body)
+		}
+	})
+
+	t.Run("MultiValued", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.Data["a"] = "default"
+		c.Data["b"] = "default"
+
+		response, err := c.R().Form(url.Values{"a": {"1", "2"}}).Post("/form")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got url.Values
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := url.Values{"a": {"1", "2"}, "b": {"default"}}
+		if !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("JSONAfterForm", func(t *testing.T) {
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.R().Form(url.Values{"a": {"1"}}).JSON([]int{1}).Post("/content-type")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "application/json" {
+			t.Errorf("expected body %q, got %q", "application/json", response.Body)
+		}
+	})
+}
+
+func TestBodyReader(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.Client = ts.Client()
+
+	t.Run("KnownLength", func(t *testing.T) {
+		payload := strings.Repeat("x", 4096)
+		response, err := c.R().
+			Header("Content-Type", "text/plain").
+			BodyReader(io.MultiReader(strings.NewReader(payload)), int64(len(payload))).
+			Post("/echo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got Echo
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Body != payload {
+			t.Errorf("expected %d byte body, got %d bytes", len(payload), len(got.Body))
+		}
+		if got.Header.Get("Content-Length") != "4096" {
+			t.Errorf("expected Content-Length %q, got %q", "4096", got.Header.Get("Content-Length"))
+		}
+		if got.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("expected Content-Type %q, got %q", "text/plain", got.Header.Get("Content-Type"))
+		}
+	})
+
+	t.Run("ReplayOnRedirect", func(t *testing.T) {
+		response, err := c.R().BodyReader(strings.NewReader("replayed"), -1).Post("/redirect-307")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var got Echo
+		if err := json.Unmarshal([]byte(response.Body), &got); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Body != "replayed" {
+			t.Errorf("expected body %q, got %q", "replayed", got.Body)
+		}
+	})
+
+	t.Run("NotReplayable", func(t *testing.T) {
+		response, err := c.R().BodyReader(io.MultiReader(strings.NewReader("once")), 4).Post("/redirect-307")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusTemporaryRedirect {
+			t.Errorf("expected status %d, got %d", http.StatusTemporaryRedirect, response.Code)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package simplehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultMinBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff    = 10 * time.Second
+	defaultMaxRetryAfter = 30 * time.Second
+	drainLimit           = 4096
+)
+
+// RetryPolicy controls automatic retries of transient failures. The zero value
+// disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry; it doubles on every
+	// further attempt up to MaxBackoff. They default to 100ms and 10s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay, from 0 to 1, that is randomized.
+	Jitter float64
+	// RetryableStatus lists the status codes worth retrying; it defaults to
+	// 502, 503 and 504.
+	RetryableStatus []int
+	// RetryableError reports whether a transport error is worth retrying; it
+	// defaults to timeouts, refused or reset connections and unexpected EOFs.
+	RetryableError func(err error) bool
+	// RetryNonIdempotent allows retrying POST and PATCH requests. Requests
+	// with an Idempotency-Key header are always considered idempotent.
+	RetryNonIdempotent bool
+	// RespectRetryAfter retries 429 and 503 responses that carry a
+	// Retry-After header once the requested delay has passed, instead of
+	// using the backoff. MaxRetryAfter caps the total time spent waiting on
+	// Retry-After across all attempts; it defaults to 30s. When the server
+	// asks for longer, the response is returned as is.
+	RespectRetryAfter bool
+	MaxRetryAfter     time.Duration
+}
+
+// DefaultRetryPolicy returns a policy of three attempts with jittered
+// exponential backoff, suitable for most idempotent calls.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  defaultMinBackoff,
+		MaxBackoff:  2 * time.Second,
+		Jitter:      0.5, //nolint:mnd // randomize half of each delay
+	}
+}
+
+func (client *HTTPClient) SetRetryPolicy(policy RetryPolicy) {
+	policy.RetryableStatus = append([]int(nil), policy.RetryableStatus...)
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.retry = policy
+}
+
+var defaultRetryableStatus = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (p RetryPolicy) retryableStatus(code int) bool {
+	statuses := p.RetryableStatus
+	if len(statuses) == 0 {
+		statuses = defaultRetryableStatus
+	}
+	for _, s := range statuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) retryableError(err error) bool {
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	return transientError(err)
+}
+
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter reads a Retry-After header given either as a number of
+// seconds or as an HTTP date. Dates in the past yield a zero delay.
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := time.Until(t); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+func (p RetryPolicy) maxRetryAfter() time.Duration {
+	if p.MaxRetryAfter <= 0 {
+		return defaultMaxRetryAfter
+	}
+	return p.MaxRetryAfter
+}
+
+func throttled(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// canRetry reports whether req may be sent again at all: its method must be
+// safe to repeat and its body, if any, must be replayable.
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	if !p.RetryNonIdempotent && !idempotent(req) {
+		return false
+	}
+	return bodyReplayable(req)
+}
+
+func bodyReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff returns the delay before the given retry, counting from 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	minBackoff, maxBackoff := p.MinBackoff, p.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	d := minBackoff
+	for i := 1; i < retry && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * jitterFloat() * float64(d))
+	}
+	return d
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec // jitter need not be cryptographically secure
+)
+
+func jitterFloat() float64 {
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+	return jitterRand.Float64()
+}
+
+// sleep waits for d, returning early with the context's error if it's done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// closeBody releases the body of a request that won't be sent, as Client.Do
+// would have.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
+
+// drain discards a little of an unwanted response body so the connection can
+// be reused, then closes it.
+func drain(response *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, drainLimit))
+	response.Body.Close()
+}
+
+// attempt sends req once, subject to the host's circuit breaker and the
+// client's rate limits.
+func (client snapshot) attempt(req *http.Request) (*http.Response, error) {
+	var br *breaker
+	var generation uint64
+	if client.breakers != nil {
+		br = client.breakers.host(req.URL.Host)
+		var err error
+		if generation, err = br.allow(time.Now()); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+	if err := client.waitForLimits(req.Context(), req.URL.Host); err != nil {
+		if br != nil {
+			br.cancel(generation)
+		}
+		closeBody(req)
+		return nil, err
+	}
+
+	response, err := client.client.Do(req) //nolint:gosec // URL is caller-provided by design
+	if br != nil {
+		if errors.Is(err, context.Canceled) {
+			// the caller gave up, which says nothing about the host
+			br.cancel(generation)
+		} else {
+			br.record(generation, client.breakers.settings.IsFailure(response, err), time.Now())
+		}
+	}
+	if err == nil && client.pacer != nil {
+		now := time.Now()
+		client.pacer.update(response.Request.URL.Host, parseRateLimit(response.Header, now), now)
+	}
+	return response, err
+}
+
+// send performs req, retrying transient failures per the client's policy.
+func (client snapshot) send(req *http.Request, info *callInfo) (*http.Response, error) {
+	policy := client.retry
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		info.attempts = attempt
+		response, err := client.attempt(req)
+
+		if attempt >= policy.MaxAttempts || !policy.canRetry(req) {
+			return response, err
+		}
+
+		delay := policy.backoff(attempt)
+		if err != nil {
+			if !policy.retryableError(err) {
+				return nil, err
+			}
+		} else if retryAfter, ok := parseRetryAfter(response.Header); ok && policy.RespectRetryAfter && throttled(response.StatusCode) {
+			if waited+retryAfter > policy.maxRetryAfter() {
+				return response, nil
+			}
+			delay = retryAfter
+			waited += retryAfter
+		} else if !policy.retryableStatus(response.StatusCode) {
+			return response, nil
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			if response != nil {
+				return response, nil
+			}
+			return nil, err
+		}
+		if response != nil {
+			drain(response)
+		}
+
+		next := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if next.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		req = next
+	}
+}
@@ -0,0 +1,298 @@
+package simplehttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first n requests it sees with status, then echoes the
+// request body with 200.
+func flakyServer(t *testing.T, n int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) <= n {
+			if status == 0 {
+				// drop the connection without a response
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func fastRetries(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func TestRetry(t *testing.T) { //nolint:funlen // subtests for each retry scenario
+	t.Parallel()
+
+	t.Run("RetryableStatus", func(t *testing.T) {
+		ts, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(3))
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+		if response.Attempts != 3 || atomic.LoadInt32(calls) != 3 {
+			t.Errorf("expected 3 attempts, got %d (%d calls)", response.Attempts, atomic.LoadInt32(calls))
+		}
+	})
+
+	t.Run("GiveUp", func(t *testing.T) {
+		ts, _ := flakyServer(t, 5, http.StatusBadGateway)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(2))
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusBadGateway {
+			t.Errorf("expected status %d, got %d", http.StatusBadGateway, response.Code)
+		}
+		if response.Attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", response.Attempts)
+		}
+	})
+
+	t.Run("NonRetryableStatus", func(t *testing.T) {
+		ts, _ := flakyServer(t, 1, http.StatusInternalServerError)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(3))
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusInternalServerError || response.Attempts != 1 {
+			t.Errorf("expected a single 500 attempt, got %d after %d attempts", response.Code, response.Attempts)
+		}
+	})
+
+	t.Run("ConnectionDropped", func(t *testing.T) {
+		ts, _ := flakyServer(t, 1, 0)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(3))
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK || response.Attempts != 2 {
+			t.Errorf("expected success on attempt 2, got %d after %d attempts", response.Code, response.Attempts)
+		}
+	})
+
+	t.Run("PostNotRetried", func(t *testing.T) {
+		ts, _ := flakyServer(t, 1, http.StatusServiceUnavailable)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(3))
+
+		response, err := c.R().Data("k", "v").Post("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusServiceUnavailable || response.Attempts != 1 {
+			t.Errorf("expected a single 503 attempt, got %d after %d attempts", response.Code, response.Attempts)
+		}
+	})
+
+	t.Run("PostWithIdempotencyKey", func(t *testing.T) {
+		ts, _ := flakyServer(t, 1, http.StatusServiceUnavailable)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(fastRetries(3))
+
+		response, err := c.R().Header("Idempotency-Key", "abc").Data("k", "v").Post("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != `{"k":"v"}` || response.Attempts != 2 {
+			t.Errorf("expected replayed body on attempt 2, got %q after %d attempts", response.Body, response.Attempts)
+		}
+	})
+
+	t.Run("CanceledDuringBackoff", func(t *testing.T) {
+		ts, _ := flakyServer(t, 5, http.StatusServiceUnavailable)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Minute})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		response, err := c.GetContext(ctx, "/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusServiceUnavailable || response.Attempts != 1 {
+			t.Errorf("expected the last 503 once canceled, got %d after %d attempts", response.Code, response.Attempts)
+		}
+	})
+
+	t.Run("CustomErrorClass", func(t *testing.T) {
+		ts, _ := flakyServer(t, 1, 0)
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		policy := fastRetries(3)
+		policy.RetryableError = func(error) bool { return false }
+		c.SetRetryPolicy(policy)
+
+		_, err := c.Get("/")
+		if err == nil {
+			t.Fatal("expected error with retries disabled for this error class, got nil")
+		}
+		if errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected context error: %v", err)
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Parallel()
+	p := RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("retry %d: expected %v, got %v", i+1, w, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(1); got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("expected jittered delay within [50ms, 100ms], got %v", got)
+		}
+	}
+}
+
+// throttledServer answers the first n requests with status and the given
+// Retry-After header, then 200.
+func throttledServer(t *testing.T, n int32, status int, retryAfter string) *httptest.Server {
+	t.Helper()
+	var calls int32
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Exposed", func(t *testing.T) {
+		ts := throttledServer(t, 1, http.StatusTooManyRequests, "120")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, response.Code)
+		}
+		if response.RetryAfter != 2*time.Minute {
+			t.Errorf("expected RetryAfter %v, got %v", 2*time.Minute, response.RetryAfter)
+		}
+	})
+
+	t.Run("Honored", func(t *testing.T) {
+		ts := throttledServer(t, 2, http.StatusTooManyRequests, "0")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Hour, RespectRetryAfter: true})
+
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK || response.Attempts != 3 {
+			t.Errorf("expected success on attempt 3, got %d after %d attempts", response.Code, response.Attempts)
+		}
+	})
+
+	t.Run("OverBudget", func(t *testing.T) {
+		ts := throttledServer(t, 1, http.StatusServiceUnavailable, "60")
+		c := New(ts.URL)
+		c.Client = ts.Client()
+		c.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, RespectRetryAfter: true, MaxRetryAfter: time.Second})
+
+		start := time.Now()
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusServiceUnavailable || response.Attempts != 1 {
+			t.Errorf("expected the 503 to be returned, got %d after %d attempts", response.Code, response.Attempts)
+		}
+		if response.RetryAfter != time.Minute {
+			t.Errorf("expected RetryAfter %v, got %v", time.Minute, response.RetryAfter)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("expected no wait when over budget, took %v", time.Since(start))
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0, true},
+	}
+	for _, tc := range cases {
+		h := http.Header{}
+		if tc.value != "" {
+			h.Set("Retry-After", tc.value)
+		}
+		got, ok := parseRetryAfter(h)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: expected (%v, %v), got (%v, %v)", tc.value, tc.want, tc.ok, got, ok)
+		}
+	}
+
+	h := http.Header{}
+	h.Set("Retry-After", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if got, ok := parseRetryAfter(h); !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("expected about an hour for a future date, got %v", got)
+	}
+}
@@ -0,0 +1,200 @@
+package simplehttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sigV4TestSigner uses the credentials from AWS's published SigV4 test suite.
+func sigV4TestSigner(service string) *SigV4Signer {
+	s := NewSigV4Signer(SigV4Config{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         service,
+	})
+	s.now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+	return s
+}
+
+func TestSigV4Vectors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		service     string
+		method      string
+		url         string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:    "get-vanilla",
+			service: "service",
+			method:  http.MethodGet,
+			url:     "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:    "get-vanilla-query-order-key-case",
+			service: "service",
+			method:  http.MethodGet,
+			url:     "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:    "post-vanilla",
+			service: "service",
+			method:  http.MethodPost,
+			url:     "https://example.amazonaws.com/",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:        "post-x-www-form-urlencoded",
+			service:     "service",
+			method:      http.MethodPost,
+			url:         "https://example.amazonaws.com/",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "Param1=value1",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name:        "iam-list-users",
+			service:     "iam",
+			method:      http.MethodGet,
+			url:         "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			contentType: "application/x-www-form-urlencoded; charset=utf-8",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.body == "" {
+				req.Body, req.GetBody = http.NoBody, nil
+			}
+			s := sigV4TestSigner(tt.service)
+			payloadHash, err := sigV4PayloadHash(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// the test suite doesn't sign X-Amz-Content-Sha256, so skip Sign
+			s.sign(req, payloadHash, s.now())
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("expected Authorization %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSigV4Middleware(t *testing.T) {
+	t.Parallel()
+
+	var got http.Header
+	var gotBody string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL)
+	c.Client = ts.Client()
+	c.Use(sigV4TestSigner("execute-api").Middleware())
+
+	t.Run("replayable body is hashed", func(t *testing.T) {
+		_, err := c.R().JSON(map[string]int{"a": 1}).Post("/items")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := hashHex([]byte(`{"a":1}`)); got.Get("X-Amz-Content-Sha256") != want {
+			t.Errorf("expected X-Amz-Content-Sha256 %s, got %s", want, got.Get("X-Amz-Content-Sha256"))
+		}
+		if gotBody != `{"a":1}` {
+			t.Errorf("expected body to survive hashing, got %q", gotBody)
+		}
+		if got.Get("X-Amz-Date") != "20150830T123600Z" {
+			t.Errorf("expected X-Amz-Date 20150830T123600Z, got %s", got.Get("X-Amz-Date"))
+		}
+		auth := got.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/execute-api/aws4_request, ") ||
+			!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date,") {
+			t.Errorf("unexpected Authorization header: %s", auth)
+		}
+	})
+
+	t.Run("streamed body is unsigned", func(t *testing.T) {
+		_, err := c.R().BodyReader(io.NopCloser(strings.NewReader("stream")), -1).Put("/items")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			t.Errorf("expected UNSIGNED-PAYLOAD, got %s", got.Get("X-Amz-Content-Sha256"))
+		}
+		if gotBody != "stream" {
+			t.Errorf("expected body stream, got %q", gotBody)
+		}
+	})
+}
+
+func TestSigV4Path(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path    string
+		service string
+		want    string
+	}{
+		{"/a:b/c=d", "execute-api", "/a%3Ab/c%3Dd"},
+		{"/users/a@b.com,+1", "execute-api", "/users/a%40b.com%2C%2B1"},
+		{"/a b/c", "service", "/a%2520b/c"},
+		{"/a b/c:d", "s3", "/a%20b/c%3Ad"},
+		{"", "service", "/"},
+	}
+	for _, tt := range tests {
+		u := &url.URL{Path: tt.path}
+		if got := sigV4Path(u, tt.service); got != tt.want {
+			t.Errorf("sigV4Path(%q, %s) = %s, expected %s", tt.path, tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestSigV4Query(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query url.Values
+		want  string
+	}{
+		{url.Values{"page2": {"1"}, "page": {"1"}}, "page=1&page2=1"},
+		{url.Values{"a-b": {"1"}, "a": {"2"}, "a_": {"3"}}, "a=2&a-b=1&a_=3"},
+		{url.Values{"k": {"b", "a", "B"}}, "k=B&k=a&k=b"},
+		{url.Values{"q": {"x y"}, "p": {"1/2"}}, "p=1%2F2&q=x%20y"},
+	}
+	for _, tt := range tests {
+		if got := sigV4Query(tt.query); got != tt.want {
+			t.Errorf("sigV4Query(%v) = %s, expected %s", tt.query, got, tt.want)
+		}
+	}
+}
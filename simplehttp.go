@@ -1,32 +1,89 @@
 package simplehttp
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 )
 
 const defaultTimeout = 10 * time.Second
 
+// HTTPClient is safe for concurrent use once configured. The exported fields
+// may be assigned directly while setting the client up; after it is shared
+// between goroutines, change defaults through the Set* and Del* methods.
 type HTTPClient struct {
 	BaseURL string
 	Headers map[string]string
 	Data    map[string]string
 	Params  map[string]string
 	Client  *http.Client
+
+	mu          sync.RWMutex
+	encoding    BodyEncoding
+	retry       RetryPolicy
+	limiter     *tokenBucket
+	hostLimiter *hostBuckets
+	pacer       *quotaPacer
+	breakers    *breakers
+	middleware  []Middleware
+	auth        credentials
+	authScope   AuthScope
+	redirect    RedirectPolicy
+	proxy       proxyConfig
+
+	sensitiveHeaders []string
 }
 
 type HTTPResponse struct {
 	Body    string
 	Code    int
 	Headers map[string][]string
+	Method  string
+	Path    string
+	ResponseMeta
+}
+
+// callInfo records what happened while sending one call, beyond the final
+// response itself.
+type callInfo struct {
+	attempts int
+}
+
+// ResponseMeta describes how a response was obtained. It is embedded in both
+// HTTPResponse and StreamResponse.
+type ResponseMeta struct {
+	Attempts int
+	// RetryAfter is the delay requested by a Retry-After header, or zero.
+	RetryAfter time.Duration
+	// RateLimit is the quota advertised by RateLimit-* or X-RateLimit-*
+	// headers, or nil.
+	RateLimit *RateLimit
+	// URL is the final URL requested, after any redirects.
+	URL string
+	// Redirects lists the redirects followed, oldest first.
+	Redirects []Redirect
 }
 
-func New(baseURL string) *HTTPClient {
-	return &HTTPClient{
+func responseMeta(response *http.Response, info *callInfo) ResponseMeta {
+	retryAfter, _ := parseRetryAfter(response.Header)
+	return ResponseMeta{
+		Attempts:   info.attempts,
+		RetryAfter: retryAfter,
+		RateLimit:  parseRateLimit(response.Header, time.Now()),
+		URL:        finalURL(response),
+		Redirects:  redirectHistory(response),
+	}
+}
+
+// Option configures an HTTPClient in New.
+type Option func(*HTTPClient)
+
+func New(baseURL string, opts ...Option) *HTTPClient {
+	client := &HTTPClient{
 		BaseURL: baseURL,
 		Headers: make(map[string]string),
 		Data:    make(map[string]string),
@@ -35,47 +92,165 @@ func New(baseURL string) *HTTPClient {
 			Timeout: defaultTimeout,
 		},
 	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
+// SetTimeout swaps in a copy of the underlying *http.Client with the new
+// timeout, so requests already in flight keep the client they started with.
 func (client *HTTPClient) SetTimeout(timeout time.Duration) {
-	client.Client.Timeout = timeout
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	c := *client.Client
+	c.Timeout = timeout
+	client.Client = &c
 }
 
-func sendRequest(client *HTTPClient, path string, method string) (HTTPResponse, error) {
+// updateTransport swaps in a copy of the underlying *http.Client whose
+// transport is a clone of the current one with update applied, like
+// SetTimeout. A nil Transport is taken to be http.DefaultTransport. update
+// runs under the client's lock.
+func (client *HTTPClient) updateTransport(update func(*http.Transport)) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
 	if client.Client == nil {
-		return HTTPResponse{}, fmt.Errorf("simplehttp: %s %s: http client is nil", method, path)
+		return errors.New("simplehttp: http client is nil")
 	}
-
-	// create the request body, as appropriate
-	var requestData []byte
-	if len(client.Data) > 0 {
-		var err error
-		requestData, err = json.Marshal(client.Data)
-		if err != nil {
-			return HTTPResponse{}, fmt.Errorf("simplehttp: %s %s: marshaling request data: %w", method, path, err)
+	var t *http.Transport
+	switch rt := client.Client.Transport.(type) {
+	case nil:
+		dt, ok := http.DefaultTransport.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("simplehttp: transport is a %T, not an *http.Transport", http.DefaultTransport)
 		}
+		t = dt.Clone()
+	case *http.Transport:
+		t = rt.Clone()
+	default:
+		return fmt.Errorf("simplehttp: transport is a %T, not an *http.Transport", rt)
 	}
+	update(t)
+	c := *client.Client
+	c.Transport = t
+	client.Client = &c
+	return nil
+}
 
-	// construct the request
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", client.BaseURL, path), bytes.NewBuffer(requestData))
-	if err != nil {
-		return HTTPResponse{}, fmt.Errorf("simplehttp: %s %s: creating request: %w", method, path, err)
+func (client *HTTPClient) SetHeader(key, value string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Headers = set(client.Headers, key, value)
+}
+
+func (client *HTTPClient) DelHeader(key string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Headers = del(client.Headers, key)
+}
+
+func (client *HTTPClient) SetParam(key, value string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Params = set(client.Params, key, value)
+}
+
+func (client *HTTPClient) DelParam(key string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Params = del(client.Params, key)
+}
+
+func (client *HTTPClient) SetData(key, value string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Data = set(client.Data, key, value)
+}
+
+func (client *HTTPClient) DelData(key string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Data = del(client.Data, key)
+}
+
+func set(m map[string]string, key, value string) map[string]string {
+	if m == nil {
+		m = make(map[string]string)
 	}
-	for k, v := range client.Headers {
-		req.Header.Set(k, v)
+	m[key] = value
+	return m
+}
+
+func del(m map[string]string, key string) map[string]string {
+	delete(m, key)
+	return m
+}
+
+// snapshot is a point-in-time copy of the client defaults used by one call,
+// taken under the read lock so requests never iterate maps being written.
+type snapshot struct {
+	baseURL string
+	headers map[string]string
+	params  map[string]string
+	data    map[string]string
+	client  *http.Client
+
+	encoding    BodyEncoding
+	retry       RetryPolicy
+	limiter     *tokenBucket
+	hostLimiter *hostBuckets
+	pacer       *quotaPacer
+	breakers    *breakers
+	middleware  []Middleware
+	auth        credentials
+	authScope   AuthScope
+	redirect    RedirectPolicy
+
+	sensitiveHeaders []string
+}
+
+func (client *HTTPClient) snapshot() snapshot {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	return snapshot{
+		baseURL: client.BaseURL,
+		headers: merge(client.Headers, nil),
+		params:  merge(client.Params, nil),
+		data:    merge(client.Data, nil),
+		client:  client.Client,
+
+		encoding:    client.encoding,
+		retry:       client.retry,
+		limiter:     client.limiter,
+		hostLimiter: client.hostLimiter,
+		pacer:       client.pacer,
+		breakers:    client.breakers,
+		middleware:  client.middleware,
+		auth:        client.auth,
+		authScope:   client.authScope,
+		redirect:    client.redirect,
+
+		sensitiveHeaders: client.sensitiveHeaders,
 	}
+}
 
-	// add query params, if any
-	q := req.URL.Query()
-	for k, v := range client.Params {
-		q.Add(k, v)
+// merge returns a copy of defaults with overrides applied on top.
+func merge(defaults, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
 	}
-	req.URL.RawQuery = q.Encode()
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
 
-	// do :allthethings:
-	response, err := client.Client.Do(req) //nolint:gosec // URL is caller-provided by design
+func sendRequest(r *Request, path string, method string) (HTTPResponse, error) {
+	response, info, err := doRequest(r, path, method, false)
 	if err != nil {
-		return HTTPResponse{}, fmt.Errorf("simplehttp: %s %s: %w", method, path, err)
+		return HTTPResponse{}, err
 	}
 	defer response.Body.Close()
 
@@ -84,39 +259,143 @@ func sendRequest(client *HTTPClient, path string, method string) (HTTPResponse,
 		return HTTPResponse{}, fmt.Errorf("simplehttp: %s %s: reading response body: %w", method, path, err)
 	}
 
-	responseHeaders := make(map[string][]string)
-	for k, v := range response.Header {
-		responseHeaders[k] = v
-	}
-
 	resp := HTTPResponse{
-		Body:    string(body),
-		Code:    response.StatusCode,
-		Headers: responseHeaders,
+		Body:         string(body),
+		Code:         response.StatusCode,
+		Headers:      headerMap(response.Header),
+		Method:       method,
+		Path:         path,
+		ResponseMeta: responseMeta(response, info),
 	}
 	return resp, nil
 }
 
+func headerMap(h http.Header) map[string][]string {
+	headers := make(map[string][]string, len(h))
+	for k, v := range h {
+		headers[k] = v
+	}
+	return headers
+}
+
+// doRequest builds and sends the request, returning the response with its
+// body unread; the caller must close it. A stream isn't bound by the client
+// timeout, which would also cover reading the body.
+func doRequest(r *Request, path string, method string, stream bool) (*http.Response, *callInfo, error) {
+	client := r.client.snapshot()
+	if client.client == nil {
+		return nil, nil, fmt.Errorf("simplehttp: %s %s: http client is nil", method, path)
+	}
+
+	// create the request body, as appropriate
+	body, err := encodeBody(r, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("simplehttp: %s %s: marshaling request data: %w", method, path, err)
+	}
+
+	// construct the request
+	req, err := http.NewRequestWithContext(r.ctx, method, fmt.Sprintf("%s%s", client.baseURL, path), nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("simplehttp: %s %s: creating request: %w", method, path, err)
+	}
+	if body.open != nil {
+		req.Body, err = body.open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("simplehttp: %s %s: opening request body: %w", method, path, err)
+		}
+		req.ContentLength = body.length
+		if body.replayable {
+			req.GetBody = body.open
+		}
+	}
+	// client defaults first, so per-request headers win regardless of key casing
+	for k, v := range client.headers {
+		req.Header.Set(k, v)
+	}
+	// a form or multipart body's type describes its encoding, so it replaces
+	// a client default; a JSON body keeps a default such as
+	// application/vnd.api+json
+	if body.contentType != "" && (body.contentType != contentTypeJSON || req.Header.Get("Content-Type") == "") {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	applyAuth(req, r, client)
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	// add query params, if any; per-request values win over client defaults
+	q := req.URL.Query()
+	for k, v := range merge(client.params, r.params) {
+		q.Add(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	// do :allthethings:
+	client.client = client.httpClient(r)
+	if stream {
+		client.client.Timeout = 0
+	}
+	info := &callInfo{}
+	response, err := client.chain(info).Do(req)
+	if err == nil && response == nil {
+		err = errNoResponse
+	}
+	if err != nil {
+		// a middleware may have failed without sending the request, leaving
+		// the body open; closing it twice is harmless
+		closeBody(req)
+		if response != nil {
+			response.Body.Close()
+		}
+		return nil, nil, fmt.Errorf("simplehttp: %s %s: %w", method, path, err)
+	}
+	return response, info, nil
+}
+
 func (client *HTTPClient) Get(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodGet)
+	return client.GetContext(context.Background(), path)
+}
+
+func (client *HTTPClient) GetContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Get(path)
 }
 
 func (client *HTTPClient) Post(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodPost)
+	return client.PostContext(context.Background(), path)
+}
+
+func (client *HTTPClient) PostContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Post(path)
 }
 
 func (client *HTTPClient) Patch(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodPatch)
+	return client.PatchContext(context.Background(), path)
+}
+
+func (client *HTTPClient) PatchContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Patch(path)
 }
 
 func (client *HTTPClient) Put(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodPut)
+	return client.PutContext(context.Background(), path)
+}
+
+func (client *HTTPClient) PutContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Put(path)
 }
 
 func (client *HTTPClient) Delete(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodDelete)
+	return client.DeleteContext(context.Background(), path)
+}
+
+func (client *HTTPClient) DeleteContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Delete(path)
 }
 
 func (client *HTTPClient) Head(path string) (HTTPResponse, error) {
-	return sendRequest(client, path, http.MethodHead)
+	return client.HeadContext(context.Background(), path)
+}
+
+func (client *HTTPClient) HeadContext(ctx context.Context, path string) (HTTPResponse, error) {
+	return client.R().WithContext(ctx).Head(path)
 }
@@ -1,7 +1,9 @@
 package simplehttp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +13,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -69,6 +72,7 @@ func TestWrapperMethods(t *testing.T) { //nolint:funlen // subtests for each HTT
 		got := response
 		wantBody := `{"header":{"Accept-Encoding":["gzip"],` +
 			`"Content-Length":["15"],` +
+			`"Content-Type":["application/json"],` +
 			`"User-Agent":["Go-http-client/1.1"]},` +
 			`"body":"{\"key\":\"value\"}"}`
 		want := HTTPResponse{
@@ -317,6 +321,99 @@ func TestSetTimeout(t *testing.T) {
 	})
 }
 
+func TestContextMethods(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.Client = ts.Client()
+
+	t.Run("Background", func(t *testing.T) {
+		response, err := c.GetContext(context.Background(), "/icanhazdadjoke")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.PostContext(ctx, "/echo")
+		if err == nil {
+			t.Fatal("expected error for canceled context, got nil")
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got: %v", err)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.GetContext(ctx, "/slow")
+		if err == nil {
+			t.Fatal("expected error for exceeded deadline, got nil")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("expected request to be abandoned at the deadline, took %v", elapsed)
+		}
+	})
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.Client = ts.Client()
+	c.Headers["Accept"] = "application/json"
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			value := strconv.Itoa(i)
+			response, err := c.R().Query("worker", value).Data("worker", value).Post("/echo")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !strings.Contains(response.Body, `\"worker\":\"`+value+`\"`) {
+				errs <- fmt.Errorf("worker %d: unexpected body %q", i, response.Body)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			c.SetHeader("X-Worker", strconv.Itoa(i))
+			c.SetParam("worker", strconv.Itoa(i))
+			c.SetData("shared", strconv.Itoa(i))
+			c.DelData("shared")
+			c.SetTimeout(time.Duration(i+1) * time.Second)
+			if _, err := c.Get("/header"); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func handleHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Method", r.Method)
 	switch r.Method {
@@ -340,6 +437,11 @@ func handleGet(w http.ResponseWriter, r *http.Request) { //nolint:funlen // test
 		_, _ = w.Write(f)
 	case "/bad-request":
 		w.WriteHeader(http.StatusBadRequest)
+	case "/slow":
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
 	case "/too-many":
 		w.WriteHeader(http.StatusTooManyRequests)
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -449,6 +551,10 @@ func handlePost(w http.ResponseWriter, r *http.Request) { //nolint:funlen // tes
 		_, _ = io.Copy(io.Discard, r.Body)
 		w.Header().Set("Location", "/")
 		w.WriteHeader(http.StatusMovedPermanently)
+	case "/redirect-307":
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Location", "/echo")
+		w.WriteHeader(http.StatusTemporaryRedirect)
 	case "/content-type":
 		_, _ = io.Copy(io.Discard, r.Body)
 		_, _ = w.Write([]byte(r.Header.Get("Content-Type")))
@@ -0,0 +1,44 @@
+package simplehttp
+
+import "io"
+
+// StreamResponse is a response whose body is left unread so it can be
+// consumed incrementally. The caller must close Body.
+type StreamResponse struct {
+	Body    io.ReadCloser
+	Code    int
+	Headers map[string][]string
+	Method  string
+	Path    string
+	ResponseMeta
+}
+
+// Stream sends the request and returns as soon as the response headers
+// arrive, instead of reading the whole body into memory. The client's timeout
+// doesn't apply, since reading a large body may take longer; bound the
+// request with a context from WithContext instead.
+func (r *Request) Stream(method, path string) (*StreamResponse, error) {
+	response, info, err := doRequest(r, path, method, true)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamResponse{
+		Body:         response.Body,
+		Code:         response.StatusCode,
+		Headers:      headerMap(response.Header),
+		Method:       method,
+		Path:         path,
+		ResponseMeta: responseMeta(response, info),
+	}, nil
+}
+
+// StreamFunc sends the request and calls fn with the unread response, closing
+// the body once fn returns. fn's error is returned unchanged.
+func (r *Request) StreamFunc(method, path string, fn func(*StreamResponse) error) error {
+	resp, err := r.Stream(method, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return fn(resp)
+}
@@ -0,0 +1,91 @@
+package simplehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewTLSServer(http.HandlerFunc(handleHTTP))
+	defer ts.Close()
+	c := New(ts.URL)
+	c.Client = ts.Client()
+
+	t.Run("PartialRead", func(t *testing.T) {
+		response, err := c.R().Stream(http.MethodGet, "/download")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer response.Body.Close()
+
+		if response.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.Code)
+		}
+		if got := response.Headers["Content-Length"]; len(got) != 1 || got[0] != "104857600" {
+			t.Errorf("expected Content-Length %q, got %v", "104857600", got)
+		}
+
+		buf := make([]byte, 1024)
+		if _, err := io.ReadFull(response.Body, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf[0] != 'h' || buf[1023] != 'h' {
+			t.Errorf("unexpected body prefix %q", buf[:8])
+		}
+	})
+
+	t.Run("Func", func(t *testing.T) {
+		var got joke
+		err := c.R().StreamFunc(http.MethodGet, "/icanhazdadjoke", func(response *StreamResponse) error {
+			return json.NewDecoder(response.Body).Decode(&got)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ID != "JokeID" {
+			t.Errorf("expected id %q, got %q", "JokeID", got.ID)
+		}
+	})
+
+	t.Run("FuncError", func(t *testing.T) {
+		errStop := errors.New("stop")
+		err := c.R().StreamFunc(http.MethodGet, "/download", func(*StreamResponse) error {
+			return errStop
+		})
+		if !errors.Is(err, errStop) {
+			t.Errorf("expected handler error, got: %v", err)
+		}
+	})
+
+	t.Run("SlowBody", func(t *testing.T) {
+		slow := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("first "))
+			w.(http.Flusher).Flush()
+			time.Sleep(300 * time.Millisecond)
+			_, _ = w.Write([]byte("second"))
+		}))
+		defer slow.Close()
+		c := New(slow.URL)
+		c.Client = slow.Client()
+		c.SetTimeout(100 * time.Millisecond)
+
+		response, err := c.R().Stream(http.MethodGet, "/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatalf("expected the body to outlast the client timeout, got: %v", err)
+		}
+		if string(body) != "first second" {
+			t.Errorf("expected body %q, got %q", "first second", body)
+		}
+	})
+}
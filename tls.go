@@ -0,0 +1,173 @@
+package simplehttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AddRootCAFile trusts the PEM-encoded CA certificates in path, in addition
+// to those already trusted: the system roots, unless the transport was given
+// its own. The file is read once; unlike client certificate files, it isn't
+// watched, so call AddRootCAFile again to trust a rotated bundle. The
+// certificates already added stay trusted.
+func (client *HTTPClient) AddRootCAFile(path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // path is caller-provided by design
+	if err != nil {
+		return fmt.Errorf("simplehttp: reading CA bundle: %w", err)
+	}
+	return client.addRootCAs(path, data)
+}
+
+// AddRootCAPEM trusts the PEM-encoded CA certificates in data, like
+// AddRootCAFile.
+func (client *HTTPClient) AddRootCAPEM(data []byte) error {
+	return client.addRootCAs("PEM data", data)
+}
+
+func (client *HTTPClient) addRootCAs(source string, data []byte) error {
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return fmt.Errorf("simplehttp: no certificates found in %s", source)
+	}
+	return client.updateTLS(func(cfg *tls.Config) {
+		// the pool may be shared with other clients, so add to a copy
+		pool := cfg.RootCAs
+		if pool == nil {
+			var err error
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			pool = pool.Clone()
+		}
+		pool.AppendCertsFromPEM(data)
+		cfg.RootCAs = pool
+	})
+}
+
+// SetClientCertificateFiles presents the PEM-encoded certificate and key in
+// certFile and keyFile to servers that ask for one. The files are checked
+// for changes at each new TLS handshake and reloaded when they rotate; if a
+// reload fails, as it may while the files are half written, the previous
+// certificate is used until it succeeds.
+func (client *HTTPClient) SetClientCertificateFiles(certFile, keyFile string) error {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := reloader.load(); err != nil {
+		return fmt.Errorf("simplehttp: loading client certificate: %w", err)
+	}
+	return client.updateTLS(func(cfg *tls.Config) {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = reloader.get
+	})
+}
+
+// SetClientCertificatePEM presents the PEM-encoded certificate and key to
+// servers that ask for one.
+func (client *HTTPClient) SetClientCertificatePEM(certPEM, keyPEM []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("simplehttp: loading client certificate: %w", err)
+	}
+	return client.updateTLS(func(cfg *tls.Config) {
+		cfg.Certificates = []tls.Certificate{cert}
+		cfg.GetClientCertificate = nil
+	})
+}
+
+// SetTLSMinVersion sets the minimum TLS version, such as tls.VersionTLS12.
+func (client *HTTPClient) SetTLSMinVersion(version uint16) error {
+	switch version {
+	case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+	default:
+		return fmt.Errorf("simplehttp: unknown TLS version %#04x", version)
+	}
+	return client.updateTLS(func(cfg *tls.Config) {
+		cfg.MinVersion = version
+	})
+}
+
+// SetTLSCipherSuites limits the cipher suites offered for TLS 1.2 and
+// earlier; TLS 1.3 suites aren't configurable. Only suites from
+// tls.CipherSuites are accepted, not those Go considers insecure. With no
+// suites, Go's defaults are used.
+func (client *HTTPClient) SetTLSCipherSuites(suites ...uint16) error {
+	secure := map[uint16]bool{}
+	for _, s := range tls.CipherSuites() {
+		secure[s.ID] = true
+	}
+	for _, id := range suites {
+		if !secure[id] {
+			return fmt.Errorf("simplehttp: unsupported cipher suite %s", tls.CipherSuiteName(id))
+		}
+	}
+	return client.updateTLS(func(cfg *tls.Config) {
+		cfg.CipherSuites = append([]uint16(nil), suites...)
+	})
+}
+
+// updateTLS applies update to a copy of the transport's TLS configuration.
+func (client *HTTPClient) updateTLS(update func(*tls.Config)) error {
+	return client.updateTransport(func(t *http.Transport) {
+		cfg := t.TLSClientConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{} //nolint:gosec // MinVersion defaults to Go's own minimum
+		}
+		update(cfg)
+		t.TLSClientConfig = cfg
+	})
+}
+
+// certReloader serves a client certificate from disk, reloading it when the
+// files' modification times change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func (r *certReloader) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, err := r.load()
+	if err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	return cert, nil
+}
+
+// load returns the current certificate, rereading the files if they have
+// changed since they were last loaded.
+func (r *certReloader) load() (*tls.Certificate, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return r.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	r.cert = &cert
+	r.certMod, r.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	return r.cert, nil
+}
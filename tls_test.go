@@ -0,0 +1,269 @@
+package simplehttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues client certificates for the mTLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue returns a PEM-encoded client certificate and key for name.
+func (ca *testCA) issue(t *testing.T, name string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newMTLSServer requires client certificates from ca and answers with the
+// client's common name. Connections aren't reused, so each request makes a
+// fresh handshake.
+func newMTLSServer(t *testing.T, ca *testCA) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: ca.pool} //nolint:gosec // test server
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// serverCAPEM is the test server's self-signed certificate, PEM-encoded.
+func serverCAPEM(ts *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+}
+
+func TestTLSConfig(t *testing.T) { //nolint:funlen // subtests for each TLS setting
+	t.Parallel()
+	ca := newTestCA(t)
+
+	t.Run("CA bundle from file", func(t *testing.T) {
+		t.Parallel()
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("trusted"))
+		}))
+		t.Cleanup(ts.Close)
+
+		c := New(ts.URL)
+		if _, err := c.Get("/"); err == nil {
+			t.Fatal("expected an unknown authority error before adding the CA")
+		}
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		if err := os.WriteFile(path, serverCAPEM(ts), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := c.AddRootCAFile(path); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "trusted" {
+			t.Errorf("expected body trusted, got %q", response.Body)
+		}
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		t.Parallel()
+		c := New("https://example.com")
+		if err := c.AddRootCAPEM([]byte("not a certificate")); err == nil {
+			t.Error("expected an error for PEM without certificates")
+		}
+		if err := c.AddRootCAFile(filepath.Join(t.TempDir(), "absent.pem")); err == nil {
+			t.Error("expected an error for a missing file")
+		}
+	})
+
+	t.Run("client certificate", func(t *testing.T) {
+		t.Parallel()
+		ts := newMTLSServer(t, ca)
+		c := New(ts.URL)
+		if err := c.AddRootCAPEM(serverCAPEM(ts)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.Get("/"); err == nil {
+			t.Fatal("expected the server to reject a client without a certificate")
+		}
+
+		certPEM, keyPEM := ca.issue(t, "static")
+		if err := c.SetClientCertificatePEM(certPEM, keyPEM); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "static" {
+			t.Errorf("expected client certificate static, got %q", response.Body)
+		}
+	})
+
+	t.Run("client certificate reload", func(t *testing.T) {
+		t.Parallel()
+		ts := newMTLSServer(t, ca)
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+		write := func(name string, mod time.Time) {
+			certPEM, keyPEM := ca.issue(t, name)
+			for path, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM} {
+				if err := os.WriteFile(path, data, 0o600); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if err := os.Chtimes(path, mod, mod); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+		}
+		write("first", time.Now().Add(-time.Minute))
+
+		c := New(ts.URL)
+		_ = c.AddRootCAPEM(serverCAPEM(ts))
+		if err := c.SetClientCertificateFiles(certFile, keyFile); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "first" {
+			t.Errorf("expected client certificate first, got %q", response.Body)
+		}
+
+		write("second", time.Now())
+		response, err = c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "second" {
+			t.Errorf("expected rotated client certificate second, got %q", response.Body)
+		}
+
+		// a half-written rotation keeps the last good certificate
+		if err := os.WriteFile(keyFile, []byte("garbage"), 0o600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_ = os.Chtimes(keyFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+		response, err = c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if response.Body != "second" {
+			t.Errorf("expected the previous certificate second, got %q", response.Body)
+		}
+	})
+
+	t.Run("minimum version", func(t *testing.T) {
+		t.Parallel()
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12} //nolint:gosec // test server
+		ts.StartTLS()
+		t.Cleanup(ts.Close)
+
+		c := New(ts.URL)
+		_ = c.AddRootCAPEM(serverCAPEM(ts))
+		if _, err := c.Get("/"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := c.SetTLSMinVersion(tls.VersionTLS13); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := c.Get("/"); err == nil {
+			t.Error("expected a handshake failure against a TLS 1.2 server")
+		}
+		if err := c.SetTLSMinVersion(0x0999); err == nil {
+			t.Error("expected an error for an unknown version")
+		}
+	})
+
+	t.Run("cipher suites", func(t *testing.T) {
+		t.Parallel()
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(tls.CipherSuiteName(r.TLS.CipherSuite)))
+		}))
+		ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12} //nolint:gosec // test server
+		ts.StartTLS()
+		t.Cleanup(ts.Close)
+
+		c := New(ts.URL)
+		_ = c.AddRootCAPEM(serverCAPEM(ts))
+		err := c.SetTLSCipherSuites(tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		response, err := c.Get("/")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(response.Body, "AES_256_GCM") {
+			t.Errorf("expected an AES_256_GCM suite, got %q", response.Body)
+		}
+		if err := c.SetTLSCipherSuites(tls.TLS_RSA_WITH_RC4_128_SHA); err == nil {
+			t.Error("expected an error for an insecure cipher suite")
+		}
+	})
+}